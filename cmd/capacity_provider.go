@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+)
+
+// The Fargate capacity providers that ECS makes available to every cluster.
+const (
+	capacityProviderFargate     = "FARGATE"
+	capacityProviderFargateSpot = "FARGATE_SPOT"
+)
+
+// CapacityProvider is a single item of a capacity provider strategy.
+type CapacityProvider struct {
+	Provider string `yaml:"provider"`
+	Weight   *int64 `yaml:"weight"`
+	Base     *int64 `yaml:"base"`
+}
+
+func (p CapacityProvider) String() string {
+	result := p.Provider
+	if p.Weight != nil || p.Base != nil {
+		result = fmt.Sprintf("%s:%d", result, aws.Int64Value(p.Weight))
+	}
+	if p.Base != nil {
+		result = fmt.Sprintf("%s:%d", result, *p.Base)
+	}
+
+	return result
+}
+
+// formatCapacityProviderStrategy formats the strategy using the CLI syntax.
+func formatCapacityProviderStrategy(strategy []CapacityProvider) string {
+	items := []string{}
+	for _, provider := range strategy {
+		items = append(items, provider.String())
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// parseCapacityProvider parses the CLI syntax for a capacity provider strategy
+// item: provider[:weight[:base]], e.g. FARGATE_SPOT:3 or FARGATE:1:1.
+func parseCapacityProvider(value string) (CapacityProvider, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return CapacityProvider{}, fmt.Errorf("invalid capacity provider %q, expected provider[:weight[:base]]", value)
+	}
+
+	provider := CapacityProvider{Provider: parts[0]}
+	for idx, field := range []**int64{&provider.Weight, &provider.Base} {
+		if len(parts) <= idx+1 {
+			break
+		}
+
+		num, err := strconv.ParseInt(parts[idx+1], 10, 64)
+		if err != nil {
+			return CapacityProvider{}, fmt.Errorf("invalid capacity provider %q, weight and base must be numbers", value)
+		}
+
+		*field = aws.Int64(num)
+	}
+
+	return provider, nil
+}
+
+// validateCapacityProviderStrategy checks the strategy against the limits ECS
+// enforces so we can fail before calling RunTask.
+func validateCapacityProviderStrategy(strategy []CapacityProvider) error {
+	withBase := 0
+	for _, provider := range strategy {
+		if provider.Provider == "" {
+			return errors.New("capacity provider strategy items must have a provider")
+		}
+
+		if weight := aws.Int64Value(provider.Weight); weight < 0 || weight > 1000 {
+			return fmt.Errorf("capacity provider %s weight must be between 0 and 1000", provider.Provider)
+		}
+
+		if base := aws.Int64Value(provider.Base); base < 0 || base > 100000 {
+			return fmt.Errorf("capacity provider %s base must be between 0 and 100000", provider.Provider)
+		}
+
+		if aws.Int64Value(provider.Base) > 0 {
+			withBase++
+		}
+	}
+
+	if withBase > 1 {
+		return errors.New("only one capacity provider in a strategy can have a base")
+	}
+
+	return nil
+}
+
+// initCapacityProviderStrategy converts the capacity provider strategy from the
+// config file or --capacity-provider-strategy into a []CapacityProvider stored
+// in viper, and checks that it isn't combined with a launch type.
+func initCapacityProviderStrategy() error {
+	key := "capacity-provider-strategy"
+
+	strategy, ok := viper.Get(key).([]CapacityProvider)
+	if !ok {
+		strategy = []CapacityProvider{}
+		for _, value := range getStringList(key) {
+			provider, err := parseCapacityProvider(value)
+			if err != nil {
+				return &ConfigError{Err: err}
+			}
+
+			strategy = append(strategy, provider)
+		}
+	}
+
+	if len(strategy) == 0 {
+		return nil
+	}
+
+	if err := validateCapacityProviderStrategy(strategy); err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	// launch-type always has a default so only complain if it was given.
+	if viper.IsSet("launch-type") {
+		return &ConfigError{Err: errors.New("launch-type and capacity-provider-strategy can't be used together")}
+	}
+
+	viper.Set(key, strategy)
+	return nil
+}
+
+// getCapacityProviderStrategy reads the strategy stored by initCapacityProviderStrategy.
+func getCapacityProviderStrategy() []CapacityProvider {
+	strategy, _ := viper.Get("capacity-provider-strategy").([]CapacityProvider)
+	return strategy
+}
+
+// usesOnlyFargate reports whether every provider in the strategy is a Fargate
+// one, in which case the task must use awsvpc networking.
+func usesOnlyFargate(strategy []CapacityProvider) bool {
+	for _, provider := range strategy {
+		if provider.Provider != capacityProviderFargate && provider.Provider != capacityProviderFargateSpot {
+			return false
+		}
+	}
+
+	return len(strategy) > 0
+}
+
+// getCapacityProviderStrategyItems converts the strategy to the items ECS expects.
+func getCapacityProviderStrategyItems(strategy []CapacityProvider) []*ecs.CapacityProviderStrategyItem {
+	result := []*ecs.CapacityProviderStrategyItem{}
+	for _, provider := range strategy {
+		result = append(result, &ecs.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(provider.Provider),
+			Weight:           provider.Weight,
+			Base:             provider.Base,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCapacityProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := parseCapacityProvider("FARGATE_SPOT")
+	assert.Nil(err)
+	assert.Equal(CapacityProvider{Provider: "FARGATE_SPOT"}, actual)
+
+	actual, err = parseCapacityProvider("FARGATE_SPOT:3")
+	assert.Nil(err)
+	assert.Equal(CapacityProvider{Provider: "FARGATE_SPOT", Weight: aws.Int64(3)}, actual)
+
+	actual, err = parseCapacityProvider("FARGATE:1:2")
+	assert.Nil(err)
+	assert.Equal(CapacityProvider{Provider: "FARGATE", Weight: aws.Int64(1), Base: aws.Int64(2)}, actual)
+	assert.Equal("FARGATE:1:2", actual.String())
+
+	_, err = parseCapacityProvider("FARGATE:one")
+	assert.NotNil(err)
+
+	_, err = parseCapacityProvider(":1")
+	assert.NotNil(err)
+
+	_, err = parseCapacityProvider("FARGATE:1:2:3")
+	assert.NotNil(err)
+}
+
+func TestValidateCapacityProviderStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := []CapacityProvider{
+		{Provider: "FARGATE_SPOT", Weight: aws.Int64(3)},
+		{Provider: "FARGATE", Weight: aws.Int64(1), Base: aws.Int64(1)},
+	}
+	assert.Nil(validateCapacityProviderStrategy(valid))
+
+	twoBases := []CapacityProvider{
+		{Provider: "FARGATE_SPOT", Base: aws.Int64(1)},
+		{Provider: "FARGATE", Base: aws.Int64(1)},
+	}
+	assert.EqualError(validateCapacityProviderStrategy(twoBases), "only one capacity provider in a strategy can have a base")
+
+	heavy := []CapacityProvider{{Provider: "FARGATE", Weight: aws.Int64(1001)}}
+	assert.NotNil(validateCapacityProviderStrategy(heavy))
+
+	unnamed := []CapacityProvider{{Weight: aws.Int64(1)}}
+	assert.NotNil(validateCapacityProviderStrategy(unnamed))
+}
+
+func TestInitCapacityProviderStrategy(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	// From the CLI.
+	viper.Set("capacity-provider-strategy", []string{"FARGATE_SPOT:4", "FARGATE:1"})
+	assert.Nil(initCapacityProviderStrategy())
+	assert.Equal([]CapacityProvider{
+		{Provider: "FARGATE_SPOT", Weight: aws.Int64(4)},
+		{Provider: "FARGATE", Weight: aws.Int64(1)},
+	}, getCapacityProviderStrategy())
+
+	// From the config file.
+	viper.Reset()
+	viper.MergeConfigMap(map[string]interface{}{
+		"capacity-provider-strategy": []CapacityProvider{{Provider: "FARGATE_SPOT"}},
+	})
+	assert.Nil(initCapacityProviderStrategy())
+	assert.Equal([]CapacityProvider{{Provider: "FARGATE_SPOT"}}, getCapacityProviderStrategy())
+
+	// Can't be combined with a launch type.
+	viper.Set("launch-type", "FARGATE")
+	err := initCapacityProviderStrategy()
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "can't be used together")
+
+	// Nothing to do without a strategy.
+	viper.Reset()
+	viper.Set("launch-type", "FARGATE")
+	assert.Nil(initCapacityProviderStrategy())
+	assert.Empty(getCapacityProviderStrategy())
+}
+
+func TestUsesOnlyFargate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(usesOnlyFargate([]CapacityProvider{}))
+	assert.True(usesOnlyFargate([]CapacityProvider{{Provider: "FARGATE_SPOT"}, {Provider: "FARGATE"}}))
+	assert.False(usesOnlyFargate([]CapacityProvider{{Provider: "FARGATE_SPOT"}, {Provider: "my-asg-provider"}}))
+}
+
+func TestBuildRunTaskInputCapacityProviderStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:                  "cluster",
+		TaskDefinition:           "task",
+		ContainerName:            "task",
+		CapacityProviderStrategy: []CapacityProvider{{Provider: "FARGATE_SPOT", Weight: aws.Int64(1)}},
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Nil(input.LaunchType)
+	assert.Equal([]*ecs.CapacityProviderStrategyItem{
+		{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)},
+	}, input.CapacityProviderStrategy)
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigEntry is the schema of a single named entry in an ecsrun.yaml config
+// file. Each key matches the CLI flag of the same name. Optional scalars are
+// pointers so we can tell an unset key apart from its zero value.
+type ConfigEntry struct {
+	Cluster                  *string                     `yaml:"cluster"`
+	Task                     *string                     `yaml:"task"`
+	Revision                 *string                     `yaml:"revision"`
+	Name                     *string                     `yaml:"name"`
+	LaunchType               *string                     `yaml:"launch-type"`
+	CapacityProviderStrategy []CapacityProvider          `yaml:"capacity-provider-strategy"`
+	SpotFallback             *int                        `yaml:"spot-fallback"`
+	TaskRole                 *string                     `yaml:"task-role"`
+	ExecutionRole            *string                     `yaml:"execution-role"`
+	Cmd                      []string                    `yaml:"cmd"`
+	ShellCmd                 *string                     `yaml:"shell-cmd"`
+	Env                      map[string]string           `yaml:"env"`
+	Containers               map[string]*ContainerConfig `yaml:"containers"`
+	EnvFile                  []string                    `yaml:"env-file"`
+	EnvS3File                []string                    `yaml:"env-s3-file"`
+	Count                    *int64                      `yaml:"count"`
+	CPU                      *int64                      `yaml:"cpu"`
+	Memory                   *int64                      `yaml:"memory"`
+	ContainerCPU             *int64                      `yaml:"container-cpu"`
+	ContainerMemory          *int64                      `yaml:"container-memory"`
+	MemoryReservation        *int64                      `yaml:"memory-reservation"`
+	EphemeralStorage         *int64                      `yaml:"ephemeral-storage"`
+	NetworkMode              *string                     `yaml:"network-mode"`
+	Subnet                   stringList                  `yaml:"subnet"`
+	SecurityGroup            stringList                  `yaml:"security-group"`
+	Public                   *bool                       `yaml:"public"`
+	NoTags                   *bool                       `yaml:"no-tags"`
+	Tags                     map[string]string           `yaml:"tags"`
+	PropagateTags            *string                     `yaml:"propagate-tags"`
+	Wait                     *bool                       `yaml:"wait"`
+	Logs                     *bool                       `yaml:"logs"`
+	OnInterrupt              *string                     `yaml:"on-interrupt"`
+	Timeout                  *string                     `yaml:"timeout"`
+	Retries                  *RetryPolicy                `yaml:"retries"`
+	Timeline                 *string                     `yaml:"timeline"`
+	Region                   *string                     `yaml:"region"`
+	Profile                  *string                     `yaml:"profile"`
+}
+
+// ToMap converts the entry to a map of the keys that are set in it, keyed by
+// their flag name, so it can be merged into viper.
+func (e *ConfigEntry) ToMap() map[string]interface{} {
+	result := make(map[string]interface{})
+
+	val := reflect.ValueOf(e).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if field.IsNil() {
+			continue
+		}
+
+		key := strings.Split(val.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		switch value := field.Interface().(type) {
+		case stringList:
+			result[key] = []string(value)
+		default:
+			if field.Kind() == reflect.Ptr {
+				result[key] = field.Elem().Interface()
+			} else {
+				result[key] = value
+			}
+		}
+	}
+
+	return result
+}
+
+// stringList is a list of strings in the config file that can also be given
+// as a single scalar string for backwards compatibility.
+type stringList []string
+
+// UnmarshalYAML accepts either a single string or a list of strings.
+func (l *stringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+var (
+	yamlLineRegex      = regexp.MustCompile(`^line (\d+): `)
+	yamlFieldRegex     = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+	yamlUnmarshalRegex = regexp.MustCompile("^cannot unmarshal !!(\\w+) ?(`.*`)? into (\\S+)$")
+)
+
+// parseConfigFile decodes the contents of the given config file into its
+// entries. Only the file's syntax is checked here: each entry is checked when
+// it's picked by getConfigEntry, so a mistake in one entry doesn't break the
+// others. The entries are decoded into plain maps rather than a yaml.MapSlice
+// since that drops the keys merged in with <<.
+func parseConfigFile(filename string, contents []byte) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	err := yaml.Unmarshal(contents, &config)
+	if err == nil {
+		return config, nil
+	}
+
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return nil, &ConfigError{Err: fmt.Errorf("%s: %s", filename, err)}
+	}
+
+	problems := []string{}
+	for _, problem := range typeErr.Errors {
+		problems = append(problems, "  "+yamlLineRegex.ReplaceAllString(problem, filename+":$1: "))
+	}
+
+	return nil, &ConfigError{Err: fmt.Errorf("invalid config file %s:\n%s", filename, strings.Join(problems, "\n"))}
+}
+
+// getConfigEntry looks up the given entry in the config file and strictly
+// decodes it. If it doesn't exist then the error lists the entries that do and
+// suggests the closest one.
+func getConfigEntry(config map[string]interface{}, filename, name string) (*ConfigEntry, error) {
+	if raw, ok := config[name]; ok {
+		return decodeConfigEntry(filename, name, raw)
+	}
+
+	names := []string{}
+	for key := range config {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	msg := fmt.Sprintf("config entry '%s' not found in %s. Available entries: %s", name, filename, strings.Join(names, ", "))
+	if suggestion := suggestConfigEntry(names, name); suggestion != "" {
+		msg = fmt.Sprintf("%s. Did you mean '%s'?", msg, suggestion)
+	}
+
+	return nil, &ConfigError{Err: errors.New(msg)}
+}
+
+// decodeConfigEntry strictly decodes the given entry one key at a time so
+// that unknown keys and values of the wrong type are reported by their key.
+func decodeConfigEntry(filename, name string, raw interface{}) (*ConfigEntry, error) {
+	entry := &ConfigEntry{}
+
+	// An entry with no keys at all decodes to nil.
+	if raw == nil {
+		return entry, nil
+	}
+
+	values, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid config entry '%s' in %s: expected a map of keys, got %v", name, filename, raw)}
+	}
+
+	byKey := make(map[string]interface{})
+	keys := []string{}
+	for key, value := range values {
+		byKey[fmt.Sprint(key)] = value
+		keys = append(keys, fmt.Sprint(key))
+	}
+	sort.Strings(keys)
+
+	known := configEntryKeys()
+	problems := []string{}
+	for _, key := range keys {
+		if !known[key] {
+			problem := fmt.Sprintf("unknown key '%s'", key)
+			if suggestion := suggestConfigEntry(sortedKeys(known), key); suggestion != "" {
+				problem = fmt.Sprintf("%s, did you mean '%s'?", problem, suggestion)
+			}
+
+			problems = append(problems, problem)
+			continue
+		}
+
+		contents, err := yaml.Marshal(map[string]interface{}{key: byKey[key]})
+		if err == nil {
+			err = yaml.UnmarshalStrict(contents, entry)
+		}
+
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, problem := range typeErr.Errors {
+				problems = append(problems, fmt.Sprintf("%s: %s", key, describeYAMLProblem(problem)))
+			}
+		} else if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid config entry '%s' in %s:\n  %s", name, filename, strings.Join(problems, "\n  "))}
+	}
+
+	return entry, nil
+}
+
+// configEntryKeys returns the keys a ConfigEntry accepts.
+func configEntryKeys() map[string]bool {
+	keys := make(map[string]bool)
+
+	entryType := reflect.TypeOf(ConfigEntry{})
+	for i := 0; i < entryType.NumField(); i++ {
+		keys[strings.Split(entryType.Field(i).Tag.Get("yaml"), ",")[0]] = true
+	}
+
+	return keys
+}
+
+func sortedKeys(keys map[string]bool) []string {
+	result := []string{}
+	for key := range keys {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// describeYAMLProblem rewrites one of yaml's decoding errors in terms of the
+// config file instead of the Go types it's decoded into. The line is dropped
+// since it's the line in the single key we decoded, not in the file.
+func describeYAMLProblem(problem string) string {
+	problem = yamlLineRegex.ReplaceAllString(problem, "")
+
+	if match := yamlFieldRegex.FindStringSubmatch(problem); match != nil {
+		return fmt.Sprintf("unknown key '%s'", match[1])
+	}
+
+	match := yamlUnmarshalRegex.FindStringSubmatch(problem)
+	if match == nil {
+		return problem
+	}
+
+	got := match[2]
+	if got == "" {
+		got = describeYAMLKind(match[1])
+	}
+
+	return fmt.Sprintf("expected %s, got %s", describeGoType(match[3]), got)
+}
+
+// describeYAMLKind describes the given yaml tag, e.g. seq for !!seq.
+func describeYAMLKind(kind string) string {
+	switch kind {
+	case "seq":
+		return "a list"
+	case "map":
+		return "a map"
+	case "str":
+		return "a string"
+	case "int", "float":
+		return "a number"
+	default:
+		return kind
+	}
+}
+
+// describeGoType describes what a config value decoded into the given Go type
+// should look like.
+func describeGoType(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "[]"):
+		return "a list"
+	case strings.HasPrefix(goType, "map["), strings.Contains(goType, "."):
+		return "a map"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"), strings.HasPrefix(goType, "float"):
+		return "a number"
+	case goType == "bool":
+		return "true or false"
+	case goType == "string":
+		return "a string"
+	default:
+		return goType
+	}
+}
+
+// suggestConfigEntry returns the name closest to the given one if it's close
+// enough to likely be a typo, otherwise an empty string.
+func suggestConfigEntry(names []string, name string) string {
+	best := ""
+	bestDistance := len(name)/3 + 2
+
+	for _, candidate := range names {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// levenshtein computes the edit distance between the given strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+// parseConfigEntry parses the given config file and decodes the given entry.
+func parseConfigEntry(contents []byte, name string) (*ConfigEntry, error) {
+	config, err := parseConfigFile("ecsrun.yaml", contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigEntry(config, "ecsrun.yaml", name)
+}
+
+// Tests
+/////////
+
+func TestParseConfigFile(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`default: &default
+  cluster: test-cluster
+  task: test-task
+  count: 3
+  public: false
+  cmd:
+    - echo
+    - hello
+
+migrate:
+  <<: *default
+  task: migrate-task
+`)
+
+	entry, err := parseConfigEntry(contents, "migrate")
+	assert.Nil(err)
+
+	migrate := entry.ToMap()
+	assert.Equal("test-cluster", migrate["cluster"])
+	assert.Equal("migrate-task", migrate["task"])
+	assert.Equal(int64(3), migrate["count"])
+	assert.Equal(false, migrate["public"])
+	assert.Equal([]string{"echo", "hello"}, migrate["cmd"])
+
+	_, isSet := migrate["subnet"]
+	assert.False(isSet)
+}
+
+func TestParseConfigFileEphemeralStorage(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`default:
+  ephemeral-storage: 100
+`)
+
+	entry, err := parseConfigEntry(contents, "default")
+	assert.Nil(err)
+	assert.Equal(int64(100), entry.ToMap()["ephemeral-storage"])
+}
+
+func TestParseConfigFileTags(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`default:
+  no-tags: true
+  propagate-tags: TASK_DEFINITION
+  tags:
+    team: data
+`)
+
+	entry, err := parseConfigEntry(contents, "default")
+	assert.Nil(err)
+
+	values := entry.ToMap()
+	assert.Equal(true, values["no-tags"])
+	assert.Equal("TASK_DEFINITION", values["propagate-tags"])
+	assert.Equal(map[string]string{"team": "data"}, values["tags"])
+}
+
+func TestParseConfigFileStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`default:
+  cluster: test-cluster
+  clustr: typo
+  count: three
+  public: maybe
+  containers:
+    app:
+      cpu: [1]
+
+other:
+  cuont: 2
+`)
+
+	config, err := parseConfigFile("ecsrun.yaml", contents)
+	assert.Nil(err)
+
+	_, err = getConfigEntry(config, "ecsrun.yaml", "default")
+	assert.IsType(&ConfigError{}, err)
+	assert.Equal(`invalid config entry 'default' in ecsrun.yaml:
+  unknown key 'clustr', did you mean 'cluster'?
+  containers: expected a number, got a list
+  count: expected a number, got `+"`three`"+`
+  public: expected true or false, got `+"`maybe`"+``, err.Error())
+	assert.NotContains(err.Error(), "cmd.")
+
+	// A typo in one entry doesn't break the others.
+	_, err = getConfigEntry(config, "ecsrun.yaml", "other")
+	assert.Contains(err.Error(), "invalid config entry 'other' in ecsrun.yaml:\n  unknown key 'cuont', did you mean 'count'?")
+
+	_, err = parseConfigFile("ecsrun.yaml", []byte("default: [not, a, map"))
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "ecsrun.yaml: yaml:")
+}
+
+func TestGetConfigEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	config := map[string]interface{}{
+		"default": map[interface{}]interface{}{"cluster": "test-cluster"},
+		"migrate": map[interface{}]interface{}{},
+		"empty":   nil,
+		"list":    []interface{}{"a"},
+	}
+
+	entry, err := getConfigEntry(config, "ecsrun.yaml", "default")
+	assert.Nil(err)
+	assert.Equal("test-cluster", *entry.Cluster)
+
+	entry, err = getConfigEntry(config, "ecsrun.yaml", "empty")
+	assert.Nil(err)
+	assert.Empty(entry.ToMap())
+
+	_, err = getConfigEntry(config, "ecsrun.yaml", "migrat")
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "config entry 'migrat' not found in ecsrun.yaml")
+	assert.Contains(err.Error(), "Available entries: default, empty, list, migrate")
+	assert.Contains(err.Error(), "Did you mean 'migrate'?")
+
+	_, err = getConfigEntry(config, "ecsrun.yaml", "something-else")
+	assert.NotContains(err.Error(), "Did you mean")
+
+	_, err = getConfigEntry(config, "ecsrun.yaml", "list")
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "invalid config entry 'list' in ecsrun.yaml: expected a map of keys")
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, levenshtein("migrate", "migrate"))
+	assert.Equal(1, levenshtein("migrat", "migrate"))
+	assert.Equal(3, levenshtein("kitten", "sitting"))
+	assert.Equal(7, levenshtein("", "migrate"))
+}
+
+func TestParseConfigFileStringList(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`default:
+  subnet: subnet-1
+  security-group:
+    - sg-app
+    - sg-db
+`)
+
+	entry, err := parseConfigEntry(contents, "default")
+	assert.Nil(err)
+
+	values := entry.ToMap()
+	assert.Equal([]string{"subnet-1"}, values["subnet"])
+	assert.Equal([]string{"sg-app", "sg-db"}, values["security-group"])
+
+	_, err = parseConfigEntry([]byte("default:\n  subnet:\n    a: b\n"), "default")
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "subnet: expected a list, got a map")
+}
+
+func TestParseConfigFileCapacityProviderStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`nightly:
+  capacity-provider-strategy:
+    - provider: FARGATE_SPOT
+      weight: 4
+    - provider: FARGATE
+      weight: 1
+      base: 1
+`)
+
+	entry, err := parseConfigEntry(contents, "nightly")
+	assert.Nil(err)
+
+	strategy := entry.ToMap()["capacity-provider-strategy"].([]CapacityProvider)
+	assert.Len(strategy, 2)
+	assert.Equal("FARGATE:1:1", strategy[1].String())
+
+	_, err = parseConfigEntry([]byte("nightly:\n  capacity-provider-strategy:\n    - name: FARGATE\n"), "nightly")
+	assert.Contains(err.Error(), "capacity-provider-strategy: unknown key 'name'")
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+)
+
+// ContainerConfig is the schema of a single container in the containers map
+// of a config entry. It overrides a container other than the one given by
+// name, e.g. a sidecar, or adds to the overrides of the one that is.
+type ContainerConfig struct {
+	Cmd               []string          `yaml:"cmd"`
+	ShellCmd          *string           `yaml:"shell-cmd"`
+	Env               map[string]string `yaml:"env"`
+	CPU               *int64            `yaml:"cpu"`
+	Memory            *int64            `yaml:"memory"`
+	MemoryReservation *int64            `yaml:"memory-reservation"`
+}
+
+// initContainers validates the containers map from the config file and splits
+// any shell-cmd into its cmd so the map is ready to be used in overrides.
+func initContainers() error {
+	containers := getContainers()
+	for name, container := range containers {
+		if err := initContainer(name, container); err != nil {
+			return &ConfigError{Err: fmt.Errorf("containers.%s: %s", name, err)}
+		}
+	}
+
+	viper.Set("containers", containers)
+	return nil
+}
+
+func initContainer(name string, container *ContainerConfig) error {
+	if name == "" {
+		return errors.New("container name can't be empty")
+	}
+
+	if container.ShellCmd != nil {
+		if len(container.Cmd) > 0 {
+			return errors.New("only one of cmd, shell-cmd can be used to give the command")
+		}
+
+		words, err := splitShellWords(*container.ShellCmd)
+		if err != nil {
+			return fmt.Errorf("unable to parse shell-cmd: %s", err)
+		}
+
+		container.Cmd = words
+		container.ShellCmd = nil
+	}
+
+	for envName := range container.Env {
+		if !envNameRegex.MatchString(envName) {
+			return fmt.Errorf("invalid environment variable name %q", envName)
+		}
+	}
+
+	for key, value := range map[string]*int64{
+		"cpu":                container.CPU,
+		"memory":             container.Memory,
+		"memory-reservation": container.MemoryReservation,
+	} {
+		if aws.Int64Value(value) < 0 {
+			return fmt.Errorf("%s can't be negative", key)
+		}
+	}
+
+	if container.Memory != nil && aws.Int64Value(container.MemoryReservation) > *container.Memory {
+		return errors.New("memory-reservation can't be more than memory")
+	}
+
+	return nil
+}
+
+// getContainers reads the containers map from the config file out of viper.
+func getContainers() map[string]*ContainerConfig {
+	containers, _ := viper.Get("containers").(map[string]*ContainerConfig)
+	if containers == nil {
+		return map[string]*ContainerConfig{}
+	}
+
+	// Entries with no keys at all decode to nil.
+	for name, container := range containers {
+		if container == nil {
+			containers[name] = &ContainerConfig{}
+		}
+	}
+
+	return containers
+}
+
+// mergeContainerOverride adds the given container config to the override.
+// Anything already set in the override, i.e. from the CLI flags for the
+// container given by name, takes precedence.
+func mergeContainerOverride(override *ecs.ContainerOverride, container *ContainerConfig) {
+	if len(override.Command) == 0 && len(container.Cmd) > 0 {
+		override.Command = aws.StringSlice(container.Cmd)
+	}
+
+	if len(container.Env) > 0 {
+		env := make(map[string]string)
+		for name, value := range container.Env {
+			env[name] = value
+		}
+		for _, pair := range override.Environment {
+			env[aws.StringValue(pair.Name)] = aws.StringValue(pair.Value)
+		}
+
+		override.Environment = getKeyValuePairs(env)
+	}
+
+	if override.Cpu == nil {
+		override.Cpu = container.CPU
+	}
+
+	if override.Memory == nil {
+		override.Memory = container.Memory
+	}
+
+	if override.MemoryReservation == nil {
+		override.MemoryReservation = container.MemoryReservation
+	}
+}
+
+// getContainerOverrides builds the overrides for every container in the given
+// containers map other than the main one, sorted by name.
+func getContainerOverrides(containers map[string]*ContainerConfig, mainContainer string) []*ecs.ContainerOverride {
+	names := []string{}
+	for name := range containers {
+		if name != mainContainer {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := []*ecs.ContainerOverride{}
+	for _, name := range names {
+		override := &ecs.ContainerOverride{Name: aws.String(name)}
+		mergeContainerOverride(override, containers[name])
+		result = append(result, override)
+	}
+
+	return result
+}
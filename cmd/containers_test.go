@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestInitContainers(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	contents := []byte(`backfill:
+  containers:
+    App:
+      shell-cmd: ./backfill --since '2 days ago'
+      env:
+        MODE: backfill
+      cpu: 2048
+    log-router:
+      memory: 512
+    empty:
+`)
+
+	entry, err := parseConfigEntry(contents, "backfill")
+	assert.Nil(err)
+	assert.Nil(viper.MergeConfigMap(entry.ToMap()))
+	assert.Nil(initContainers())
+
+	// Container names keep their case.
+	containers := getContainers()
+	assert.Len(containers, 3)
+	assert.Equal([]string{"./backfill", "--since", "2 days ago"}, containers["App"].Cmd)
+	assert.Nil(containers["App"].ShellCmd)
+	assert.Equal(int64(512), *containers["log-router"].Memory)
+	assert.Equal(&ContainerConfig{}, containers["empty"])
+}
+
+func TestInitContainerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	shellCmd := "echo 'hi"
+	assert.EqualError(initContainer("app", &ContainerConfig{ShellCmd: &shellCmd}), "unable to parse shell-cmd: unterminated single quote in command")
+
+	shellCmd = "echo hi"
+	assert.EqualError(initContainer("app", &ContainerConfig{ShellCmd: &shellCmd, Cmd: []string{"echo"}}), "only one of cmd, shell-cmd can be used to give the command")
+
+	assert.EqualError(initContainer("app", &ContainerConfig{Env: map[string]string{"1BAD": "x"}}), `invalid environment variable name "1BAD"`)
+	assert.EqualError(initContainer("app", &ContainerConfig{CPU: aws.Int64(-1)}), "cpu can't be negative")
+	assert.EqualError(initContainer("app", &ContainerConfig{Memory: aws.Int64(512), MemoryReservation: aws.Int64(1024)}), "memory-reservation can't be more than memory")
+}
+
+func TestBuildRunTaskInputContainers(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:        "cluster",
+		TaskDefinition: "task",
+		ContainerName:  "app",
+		LaunchType:     ecs.LaunchTypeEc2,
+		Command:        []*string{aws.String("./migrate")},
+		Environment:    map[string]string{"MODE": "cli"},
+		ContainerCPU:   1024,
+		Containers: map[string]*ContainerConfig{
+			"app": {
+				Cmd:    []string{"./backfill"},
+				Env:    map[string]string{"MODE": "config", "DEBUG": "1"},
+				CPU:    aws.Int64(2048),
+				Memory: aws.Int64(4096),
+			},
+			"worker":    {Cmd: []string{"./work"}},
+			"log-proxy": {Env: map[string]string{"LEVEL": "debug"}, MemoryReservation: aws.Int64(128)},
+		},
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	overrides := input.Overrides.ContainerOverrides
+	assert.Len(overrides, 3)
+
+	// The flags for the main container win over its containers entry.
+	assert.Equal("app", *overrides[0].Name)
+	assert.Equal([]*string{aws.String("./migrate")}, overrides[0].Command)
+	assert.Equal([]*ecs.KeyValuePair{
+		{Name: aws.String("DEBUG"), Value: aws.String("1")},
+		{Name: aws.String("MODE"), Value: aws.String("cli")},
+	}, overrides[0].Environment)
+	assert.Equal(int64(1024), *overrides[0].Cpu)
+	assert.Equal(int64(4096), *overrides[0].Memory)
+
+	// Other containers are sorted by name.
+	assert.Equal("log-proxy", *overrides[1].Name)
+	assert.Nil(overrides[1].Command)
+	assert.Equal(int64(128), *overrides[1].MemoryReservation)
+	assert.Equal("worker", *overrides[2].Name)
+	assert.Equal([]*string{aws.String("./work")}, overrides[2].Command)
+
+	// Without a command for the main container its containers entry is used.
+	config.Command = []*string{}
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+	assert.Equal([]*string{aws.String("./backfill")}, input.Overrides.ContainerOverrides[0].Command)
+}
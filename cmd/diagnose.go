@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// failurePattern is a known way for a task to fail. A pattern matches a task
+// if every one of StopCode, Reason and ExitCode that's given matches. Reason is
+// matched against the task's stoppedReason and the reason of each container.
+type failurePattern struct {
+	StopCode string
+	Reason   *regexp.Regexp
+	ExitCode int
+	Network  bool
+	Summary  string
+	Causes   []string
+}
+
+// knownFailures is checked in order and the first match wins, so the more
+// specific patterns come first.
+var knownFailures = []failurePattern{
+	{
+		Reason:  regexp.MustCompile(`OutOfMemoryError`),
+		Summary: "A container ran out of memory and was killed.",
+		Causes: []string{
+			"The container used more than its hard memory limit. Raise it with --container-memory or the task's with --memory.",
+			"The job's memory use grows with its input, e.g. loading a whole table or file into memory at once.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`CannotPullContainerError`),
+		Network: true,
+		Summary: "ECS couldn't pull the container image.",
+		Causes: []string{
+			"The image or tag in the task definition doesn't exist, or has a typo.",
+			"The execution role is missing ecr:GetAuthorizationToken, ecr:BatchGetImage or ecr:GetDownloadUrlForLayer. Set it with --execution-role.",
+			"The image is in a private registry and the task definition's repository credentials are missing or wrong.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`(?i)unable to (pull|retrieve) secrets?`),
+		Network: true,
+		Summary: "ECS couldn't fetch the task's secrets.",
+		Causes: []string{
+			"The execution role is missing secretsmanager:GetSecretValue or ssm:GetParameters, or kms:Decrypt for a customer managed key.",
+			"A secret or parameter referenced by the task definition doesn't exist, or is in another region or account.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`(?i)ResourceInitializationError.*log`),
+		Network: true,
+		Summary: "ECS couldn't set up the task's logging.",
+		Causes: []string{
+			"The awslogs log group in the task definition doesn't exist. Create it or set awslogs-create-group.",
+			"The execution role is missing logs:CreateLogStream or logs:PutLogEvents.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`ResourceInitializationError`),
+		Network: true,
+		Summary: "ECS couldn't set up the task's resources before starting it.",
+		Causes: []string{
+			"The execution role is missing permissions the task definition needs, e.g. for secrets, env files or logs.",
+			"The subnet has run out of free IP addresses.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`CannotStartContainerError`),
+		Summary: "The container runtime couldn't start the container.",
+		Causes: []string{
+			"The command or entrypoint doesn't exist in the image. Check --cmd and the image's PATH.",
+			"The image was built for a different CPU architecture than the task runs on (exec format error).",
+		},
+	},
+	{
+		StopCode: stopCodeSpotInterruption,
+		Summary:  "Fargate Spot reclaimed the capacity the task was running on.",
+		Causes: []string{
+			"Spot capacity can be reclaimed at any time. Use --spot-fallback to relaunch on on-demand Fargate instead.",
+		},
+	},
+	{
+		Reason:  regexp.MustCompile(`^` + timeoutStopReason),
+		Summary: "ecsrun stopped the task because it ran past its --timeout.",
+		Causes: []string{
+			"The job is hung, e.g. waiting on a lock or an unreachable host. Check its logs with ecsrun logs.",
+			"The job needs longer than the timeout allows. Raise --timeout.",
+		},
+	},
+	{
+		StopCode: ecs.TaskStopCodeUserInitiated,
+		Summary:  "Someone stopped the task.",
+		Causes: []string{
+			"It was stopped with ecsrun stop, Ctrl-C or the ECS console or API. The stopped reason says by whom if it was ecsrun.",
+		},
+	},
+	{
+		ExitCode: 137,
+		Summary:  "A container was killed with SIGKILL (exit code 137).",
+		Causes: []string{
+			"The container ran out of memory. Raise --container-memory or --memory.",
+			"The container didn't exit within its stop timeout after being sent SIGTERM.",
+		},
+	},
+	{
+		ExitCode: 127,
+		Summary:  "The container's command wasn't found (exit code 127).",
+		Causes: []string{
+			"The command in --cmd doesn't exist in the image or isn't on its PATH.",
+		},
+	},
+	{
+		ExitCode: 126,
+		Summary:  "The container's command couldn't be executed (exit code 126).",
+		Causes: []string{
+			"The command in --cmd isn't executable, e.g. a script missing its execute permission.",
+		},
+	},
+	{
+		StopCode: ecs.TaskStopCodeEssentialContainerExited,
+		Summary:  "An essential container exited with an error, so ECS stopped the task.",
+		Causes: []string{
+			"The command failed. Check its logs with ecsrun logs.",
+			"A sidecar marked as essential in the task definition exited, which stops the whole task.",
+		},
+	},
+	{
+		StopCode: ecs.TaskStopCodeTaskFailedToStart,
+		Network:  true,
+		Summary:  "The task failed to start.",
+		Causes: []string{
+			"Check the stopped reason above, and the task definition's roles, image and secrets.",
+		},
+	},
+}
+
+// diagnosis is what we think went wrong with a failed task.
+type diagnosis struct {
+	Summary string   `json:"summary"`
+	Causes  []string `json:"causes"`
+}
+
+// diagnoseTask matches the given stopped task against the known failures.
+// failure is the task's failure from getTaskFailure. Returns nil if the task
+// didn't fail or we don't recognize how it failed.
+func diagnoseTask(task *ecs.Task, failure error, config *RunConfig) *diagnosis {
+	if failure == nil {
+		return nil
+	}
+
+	exitCode := 0
+	var taskFailure *TaskFailureError
+	if errors.As(failure, &taskFailure) {
+		exitCode = taskFailure.Code
+	}
+
+	reasons := []string{aws.StringValue(task.StoppedReason)}
+	for _, container := range task.Containers {
+		reasons = append(reasons, aws.StringValue(container.Reason))
+	}
+
+	for _, pattern := range knownFailures {
+		if pattern.matches(aws.StringValue(task.StopCode), reasons, exitCode) {
+			result := &diagnosis{Summary: pattern.Summary, Causes: pattern.Causes}
+			if pattern.Network {
+				result.Causes = append(append([]string{}, result.Causes...), networkCauses(config)...)
+			}
+
+			return result
+		}
+	}
+
+	return nil
+}
+
+func (p failurePattern) matches(stopCode string, reasons []string, exitCode int) bool {
+	if p.StopCode != "" && p.StopCode != stopCode {
+		return false
+	}
+
+	if p.ExitCode != 0 && p.ExitCode != exitCode {
+		return false
+	}
+
+	if p.Reason == nil {
+		return true
+	}
+
+	for _, reason := range reasons {
+		if p.Reason.MatchString(reason) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkCauses returns the likely networking causes of a task that couldn't
+// reach ECR, Secrets Manager, SSM or CloudWatch Logs.
+func networkCauses(config *RunConfig) []string {
+	if config.NetworkMode != ecs.NetworkModeAwsvpc {
+		return []string{}
+	}
+
+	if !config.AssignPublicIPFlag {
+		return []string{"The task has no public IP since --public isn't set, so a private subnet needs a NAT gateway or VPC endpoints for ECR (ecr.api, ecr.dkr and S3), Secrets Manager, SSM and CloudWatch Logs."}
+	}
+
+	return []string{"The task has a public IP, so its subnet's route table needs a route to an internet gateway."}
+}
+
+// printDiagnosis prints what we think went wrong with the given task.
+func printDiagnosis(task *ecs.Task, result *diagnosis) {
+	cyan.Fprintf(textOut, "Diagnosis for task %s:\n", getTaskID(aws.StringValue(task.TaskArn)))
+	fmt.Fprintln(textOut, result.Summary)
+	fmt.Fprintln(textOut, "Likely causes:")
+	for _, cause := range result.Causes {
+		fmt.Fprintf(textOut, "  - %s\n", cause)
+	}
+}
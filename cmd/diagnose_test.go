@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestDiagnoseTask(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{NetworkMode: ecs.NetworkModeAwsvpc}
+	failed := &TaskFailureError{Code: ExitCodeNotStarted}
+
+	cases := []struct {
+		stopCode      string
+		stoppedReason string
+		reason        string
+		summary       string
+	}{
+		{ecs.TaskStopCodeTaskFailedToStart, "CannotPullContainerError: inspect image has been retried 5 time(s)", "", "ECS couldn't pull the container image."},
+		{ecs.TaskStopCodeTaskFailedToStart, "ResourceInitializationError: unable to pull secrets or registry auth", "", "ECS couldn't fetch the task's secrets."},
+		{ecs.TaskStopCodeTaskFailedToStart, "ResourceInitializationError: failed to validate logger args", "", "ECS couldn't set up the task's logging."},
+		{ecs.TaskStopCodeEssentialContainerExited, "Essential container in task exited", "OutOfMemoryError: Container killed due to memory usage", "A container ran out of memory and was killed."},
+		{stopCodeSpotInterruption, "Your Spot Task was interrupted.", "", "Fargate Spot reclaimed the capacity the task was running on."},
+		{ecs.TaskStopCodeUserInitiated, timeoutStopReason, "", "ecsrun stopped the task because it ran past its --timeout."},
+		{ecs.TaskStopCodeUserInitiated, "Stopped with ecsrun by matt", "", "Someone stopped the task."},
+		{ecs.TaskStopCodeTaskFailedToStart, "Something new", "", "The task failed to start."},
+	}
+	for _, c := range cases {
+		result := diagnoseTask(stoppedWith(finishedTask(testTaskArn, c.stopCode, 0), c.stoppedReason, c.reason), failed, config)
+		if assert.NotNil(result, c.stoppedReason) {
+			assert.Equal(c.summary, result.Summary)
+		}
+	}
+
+	// Exit codes are diagnosed before the generic essential container exit.
+	exited := stoppedWith(finishedTask(testTaskArn, ecs.TaskStopCodeEssentialContainerExited, 0), "Essential container in task exited", "")
+	assert.Equal("The container's command wasn't found (exit code 127).", diagnoseTask(exited, &TaskFailureError{Code: 127}, config).Summary)
+	assert.Equal("An essential container exited with an error, so ECS stopped the task.", diagnoseTask(exited, &TaskFailureError{Code: 1}, config).Summary)
+
+	// Tasks that didn't fail or failed in a way we don't know aren't diagnosed.
+	assert.Nil(diagnoseTask(exited, nil, config))
+	assert.Nil(diagnoseTask(stoppedWith(finishedTask(testTaskArn, "", 0), "", ""), failed, config))
+}
+
+func TestDiagnoseTaskNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	task := stoppedWith(finishedTask(testTaskArn, ecs.TaskStopCodeTaskFailedToStart, 0), "CannotPullContainerError: timeout", "")
+	failed := &TaskFailureError{Code: ExitCodeNotStarted}
+
+	private := diagnoseTask(task, failed, &RunConfig{NetworkMode: ecs.NetworkModeAwsvpc})
+	assert.Contains(private.Causes[len(private.Causes)-1], "NAT gateway or VPC endpoints")
+
+	public := diagnoseTask(task, failed, &RunConfig{NetworkMode: ecs.NetworkModeAwsvpc, AssignPublicIPFlag: true})
+	assert.Contains(public.Causes[len(public.Causes)-1], "internet gateway")
+
+	bridge := diagnoseTask(task, failed, &RunConfig{NetworkMode: ecs.NetworkModeBridge})
+	assert.Len(bridge.Causes, 3)
+
+	// The table itself is never changed by adding the network causes.
+	assert.Len(knownFailures[1].Causes, 3)
+}
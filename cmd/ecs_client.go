@@ -1,16 +1,72 @@
 package cmd
 
 import (
-	// "fmt"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
 )
 
+// maxDescribeTasks is the most tasks DescribeTasks accepts in one call.
+const maxDescribeTasks = 100
+
+// pollInterval is how long we wait between DescribeTasks calls when waiting on tasks.
+var pollInterval = 6 * time.Second
+
+// missingGracePeriod is how long we keep polling tasks that DescribeTasks
+// reports as MISSING. ECS is eventually consistent, so tasks that were just
+// launched can take a few seconds to show up.
+var missingGracePeriod = time.Minute
+
+// failureMissing is the failure reason DescribeTasks gives for tasks it can't
+// find.
+const failureMissing = "MISSING"
+
+// missingTasksError is returned by DescribeTasks, wrapped in an APIError and
+// along with the tasks it did find, when some of the tasks couldn't be found.
+type missingTasksError struct {
+	TaskArns []string
+}
+
+func (e *missingTasksError) Error() string {
+	return fmt.Sprintf("unable to describe task %s: %s", strings.Join(e.TaskArns, ", "), failureMissing)
+}
+
+// isMissingTasks returns whether the given error is from DescribeTasks not
+// finding some of the tasks.
+func isMissingTasks(err error) bool {
+	var missing *missingTasksError
+	return errors.As(err, &missing)
+}
+
+// ignoreMissing drops the error DescribeTasks returns for tasks it can't find
+// until the given time has passed, so tasks that aren't visible yet are polled
+// again instead of failing.
+func ignoreMissing(err error, until time.Time) error {
+	if isMissingTasks(err) && time.Now().Before(until) {
+		log.Debug("Waiting for ECS to find the task(s). ", err)
+		return nil
+	}
+
+	return err
+}
+
 // ECSClient is the wrapper around the aws-sdk ECS client and its various structs / methods.
 type ECSClient interface {
 	BuildRunTaskInput() *ecs.RunTaskInput
 	RunTask(runTaskInput *ecs.RunTaskInput) (*ecs.RunTaskOutput, error)
+	ListTasks(desiredStatus string) ([]*string, error)
+	DescribeTasks(taskArns []*string) ([]*ecs.Task, error)
+	DescribeTaskDefinition() (*ecs.TaskDefinition, error)
+	StopTask(taskArn, reason string) (*ecs.Task, error)
+	WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error)
 }
 
 type ecsClient struct {
@@ -33,34 +89,269 @@ func newClient(client ecsiface.ECSAPI, config *RunConfig) ECSClient {
 }
 
 func (c *ecsClient) BuildRunTaskInput() *ecs.RunTaskInput {
-	return &ecs.RunTaskInput{
+	containerOverride := &ecs.ContainerOverride{
+		Name: &c.config.ContainerName,
+	}
+
+	if len(c.config.Command) > 0 {
+		containerOverride.Command = c.config.Command
+	}
+
+	// The ECSRUN_* env vars can be overridden like any others.
+	env := c.config.RunInfo.Environment()
+	for name, value := range c.config.Environment {
+		env[name] = value
+	}
+
+	if len(env) > 0 {
+		containerOverride.Environment = getKeyValuePairs(env)
+	}
+
+	if len(c.config.EnvironmentFiles) > 0 {
+		containerOverride.EnvironmentFiles = getEnvironmentFiles(c.config.EnvironmentFiles)
+	}
+
+	if c.config.ContainerCPU > 0 {
+		containerOverride.Cpu = &c.config.ContainerCPU
+	}
+
+	if c.config.ContainerMemory > 0 {
+		containerOverride.Memory = &c.config.ContainerMemory
+	}
+
+	if c.config.MemoryReservation > 0 {
+		containerOverride.MemoryReservation = &c.config.MemoryReservation
+	}
+
+	// The containers map from the config file can add to the overrides of the
+	// main container and override any others, e.g. sidecars.
+	if container, ok := c.config.Containers[c.config.ContainerName]; ok {
+		mergeContainerOverride(containerOverride, container)
+	}
+
+	containerOverrides := append([]*ecs.ContainerOverride{containerOverride},
+		getContainerOverrides(c.config.Containers, c.config.ContainerName)...)
+
+	input := &ecs.RunTaskInput{
 		Cluster:        &c.config.Cluster,
 		TaskDefinition: &c.config.TaskDefinition,
 		Count:          &c.config.Count,
-		LaunchType:     &c.config.LaunchType,
-		NetworkConfiguration: &ecs.NetworkConfiguration{
+		Overrides: &ecs.TaskOverride{
+			ContainerOverrides: containerOverrides,
+		},
+	}
+
+	if c.config.TaskRoleArn != "" {
+		input.Overrides.TaskRoleArn = &c.config.TaskRoleArn
+	}
+
+	if c.config.ExecutionRoleArn != "" {
+		input.Overrides.ExecutionRoleArn = &c.config.ExecutionRoleArn
+	}
+
+	// The task level overrides are strings in the API.
+	if c.config.CPU > 0 {
+		input.Overrides.Cpu = aws.String(strconv.FormatInt(c.config.CPU, 10))
+	}
+
+	if c.config.Memory > 0 {
+		input.Overrides.Memory = aws.String(strconv.FormatInt(c.config.Memory, 10))
+	}
+
+	if c.config.EphemeralStorage > 0 {
+		input.Overrides.EphemeralStorage = &ecs.EphemeralStorage{SizeInGiB: &c.config.EphemeralStorage}
+	}
+
+	// Tag the task with who ran it, from where, and which config entry, unless
+	// we were asked not to since tagging needs ecs:TagResource.
+	runInfo := c.config.RunInfo
+	if c.config.NoTags {
+		runInfo = RunInfo{}
+	}
+
+	if tags := getTags(runInfo, c.config.Tags); len(tags) > 0 {
+		input.Tags = tags
+	}
+
+	if c.config.PropagateTags == ecs.PropagateTagsTaskDefinition {
+		input.PropagateTags = &c.config.PropagateTags
+	}
+
+	if c.config.RunInfo.RunID != "" {
+		input.StartedBy = aws.String(c.config.RunInfo.StartedBy())
+	}
+
+	if group := c.config.RunInfo.Group(); group != "" {
+		input.Group = &group
+	}
+
+	// ECS only accepts one of a launch type or a capacity provider strategy.
+	if len(c.config.CapacityProviderStrategy) > 0 {
+		input.CapacityProviderStrategy = getCapacityProviderStrategyItems(c.config.CapacityProviderStrategy)
+	} else {
+		input.LaunchType = &c.config.LaunchType
+	}
+
+	// ECS rejects a network configuration for bridge / host / none network modes.
+	if c.config.NetworkMode == ecs.NetworkModeAwsvpc {
+		input.NetworkConfiguration = &ecs.NetworkConfiguration{
 			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
 				AssignPublicIp: &c.config.AssignPublicIP,
-				SecurityGroups: []*string{&c.config.SecurityGroupID},
-				Subnets:        []*string{&c.config.SubnetID},
-			},
-		},
-		Overrides: &ecs.TaskOverride{
-			ContainerOverrides: []*ecs.ContainerOverride{
-				{
-					Command: c.config.Command,
-					Name:    &c.config.ContainerName,
-				},
+				SecurityGroups: aws.StringSlice(c.config.SecurityGroupIDs),
+				Subnets:        aws.StringSlice(c.config.SubnetIDs),
 			},
-		},
+		}
 	}
+
+	return input
 }
 
 func (c *ecsClient) RunTask(runTaskInput *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
 	output, err := c.client.RunTask(runTaskInput)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecs.ErrCodeAccessDeniedException && len(runTaskInput.Tags) > 0 {
+		err = fmt.Errorf("%w\nTagging the task needs ecs:TagResource as well as ecs:RunTask. Pass --no-tags to launch it without the ecsrun tags", err)
+	}
+
 	if err != nil {
-		log.Fatal("Received error when invoking RunTask.", err)
+		return nil, &APIError{Op: "RunTask", Err: err}
 	}
 
-	return output, err
+	return output, checkRunTaskFailures(runTaskInput, output)
+}
+
+// ListTasks fetches the ARNs of every task in our cluster with the given
+// desired status, following ListTasks' pagination.
+func (c *ecsClient) ListTasks(desiredStatus string) ([]*string, error) {
+	taskArns := []*string{}
+	input := &ecs.ListTasksInput{
+		Cluster:       &c.config.Cluster,
+		DesiredStatus: &desiredStatus,
+	}
+
+	err := c.client.ListTasksPages(input, func(page *ecs.ListTasksOutput, lastPage bool) bool {
+		taskArns = append(taskArns, page.TaskArns...)
+		return true
+	})
+	if err != nil {
+		return nil, &APIError{Op: "ListTasks", Err: err}
+	}
+
+	return taskArns, nil
+}
+
+// DescribeTasks fetches the current state and tags of the given tasks in our
+// cluster. DescribeTasks only accepts 100 tasks at a time so we batch them. If
+// some of the tasks are MISSING the ones that were found are returned along
+// with a missingTasksError.
+func (c *ecsClient) DescribeTasks(taskArns []*string) ([]*ecs.Task, error) {
+	tasks := []*ecs.Task{}
+	missing := []string{}
+
+	for start := 0; start < len(taskArns); start += maxDescribeTasks {
+		end := start + maxDescribeTasks
+		if end > len(taskArns) {
+			end = len(taskArns)
+		}
+
+		output, err := c.client.DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: &c.config.Cluster,
+			Tasks:   taskArns[start:end],
+			Include: []*string{aws.String(ecs.TaskFieldTags)},
+		})
+		if err != nil {
+			return nil, &APIError{Op: "DescribeTasks", Err: err}
+		}
+
+		for _, failure := range output.Failures {
+			if aws.StringValue(failure.Reason) == failureMissing {
+				missing = append(missing, aws.StringValue(failure.Arn))
+				continue
+			}
+
+			err := fmt.Errorf("unable to describe task %s: %s", aws.StringValue(failure.Arn), aws.StringValue(failure.Reason))
+			return nil, &APIError{Op: "DescribeTasks", Err: err}
+		}
+
+		tasks = append(tasks, output.Tasks...)
+	}
+
+	if len(missing) > 0 {
+		return tasks, &APIError{Op: "DescribeTasks", Err: &missingTasksError{TaskArns: missing}}
+	}
+
+	return tasks, nil
+}
+
+// DescribeTaskDefinition fetches the Task Definition that we're running.
+func (c *ecsClient) DescribeTaskDefinition() (*ecs.TaskDefinition, error) {
+	output, err := c.client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &c.config.TaskDefinition,
+	})
+	if err != nil {
+		return nil, &APIError{Op: "DescribeTaskDefinition", Err: err}
+	}
+
+	return output.TaskDefinition, nil
+}
+
+// StopTask stops the given task in our cluster, recording the given reason as
+// its stopped reason.
+func (c *ecsClient) StopTask(taskArn, reason string) (*ecs.Task, error) {
+	output, err := c.client.StopTask(&ecs.StopTaskInput{
+		Cluster: &c.config.Cluster,
+		Task:    &taskArn,
+		Reason:  &reason,
+	})
+	if err != nil {
+		return nil, &APIError{Op: "StopTask", Err: err}
+	}
+
+	return output.Task, nil
+}
+
+// WaitForTasks polls DescribeTasks until every one of the given tasks has
+// reached the STOPPED status and then returns their final state. It gives up
+// early with the context's error if the context is done first. Tasks that
+// are MISSING are polled for up to missingGracePeriod.
+func (c *ecsClient) WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error) {
+	lastStatuses := make(map[string]string)
+	missingUntil := time.Now().Add(missingGracePeriod)
+
+	for {
+		tasks, err := c.DescribeTasks(taskArns)
+		if err := ignoreMissing(err, missingUntil); err != nil {
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			arn := aws.StringValue(task.TaskArn)
+			status := aws.StringValue(task.LastStatus)
+			if lastStatuses[arn] != status {
+				log.Debug("Task ", arn, " is now ", status)
+				lastStatuses[arn] = status
+			}
+		}
+
+		if allTasksStopped(tasks, len(taskArns)) {
+			return tasks, nil
+		}
+
+		if err := sleep(ctx, pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for the given duration or until the context is done, in which
+// case it returns the context's error.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks
+/////////
+
+type ecsAPIFake struct {
+	ecsiface.ECSAPI
+
+	describeTasksCalls  int
+	describeTasks       []*ecs.DescribeTasksOutput
+	describeTasksInputs []*ecs.DescribeTasksInput
+	taskDefinition      *ecs.TaskDefinition
+	listTasksPages      [][]*string
+	runTaskErr          error
+}
+
+func (f *ecsAPIFake) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	return &ecs.RunTaskOutput{}, f.runTaskErr
+}
+
+func (f *ecsAPIFake) ListTasksPages(input *ecs.ListTasksInput, fn func(*ecs.ListTasksOutput, bool) bool) error {
+	for idx, page := range f.listTasksPages {
+		if !fn(&ecs.ListTasksOutput{TaskArns: page}, idx == len(f.listTasksPages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *ecsAPIFake) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	f.describeTasksInputs = append(f.describeTasksInputs, input)
+	output := f.describeTasks[f.describeTasksCalls]
+	f.describeTasksCalls = f.describeTasksCalls + 1
+	return output, nil
+}
+
+func (f *ecsAPIFake) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: f.taskDefinition}, nil
+}
+
+// Tests
+/////////
+
+func TestWaitForTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	previousInterval := pollInterval
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = previousInterval }()
+
+	api := &ecsAPIFake{
+		describeTasks: []*ecs.DescribeTasksOutput{
+			{Tasks: []*ecs.Task{describedTask("arn-1", "PROVISIONING"), describedTask("arn-2", "PENDING")}},
+			{Tasks: []*ecs.Task{describedTask("arn-1", "STOPPED"), describedTask("arn-2", "RUNNING")}},
+			{Tasks: []*ecs.Task{describedTask("arn-1", "STOPPED"), describedTask("arn-2", "STOPPED")}},
+		},
+	}
+	client := newClient(api, &RunConfig{Cluster: "cluster"})
+
+	tasks, err := client.WaitForTasks(context.Background(), []*string{aws.String("arn-1"), aws.String("arn-2")})
+
+	assert.Nil(err)
+	assert.Equal(3, api.describeTasksCalls)
+	assert.Len(tasks, 2)
+}
+
+func TestWaitForTasksMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	previousInterval := pollInterval
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = previousInterval }()
+
+	missing := &ecs.DescribeTasksOutput{
+		Tasks:    []*ecs.Task{describedTask("arn-1", "PENDING")},
+		Failures: []*ecs.Failure{{Arn: aws.String("arn-2"), Reason: aws.String("MISSING")}},
+	}
+	api := &ecsAPIFake{
+		describeTasks: []*ecs.DescribeTasksOutput{
+			missing,
+			{Tasks: []*ecs.Task{describedTask("arn-1", "STOPPED"), describedTask("arn-2", "STOPPED")}},
+		},
+	}
+	client := newClient(api, &RunConfig{Cluster: "cluster"})
+
+	// A task that was just launched can be MISSING for a bit.
+	tasks, err := client.WaitForTasks(context.Background(), []*string{aws.String("arn-1"), aws.String("arn-2")})
+
+	assert.Nil(err)
+	assert.Equal(2, api.describeTasksCalls)
+	assert.Len(tasks, 2)
+
+	// But not forever.
+	previousGracePeriod := missingGracePeriod
+	missingGracePeriod = 0
+	defer func() { missingGracePeriod = previousGracePeriod }()
+
+	api = &ecsAPIFake{describeTasks: []*ecs.DescribeTasksOutput{missing}}
+	tasks, err = newClient(api, &RunConfig{Cluster: "cluster"}).WaitForTasks(context.Background(), []*string{aws.String("arn-1"), aws.String("arn-2")})
+
+	assert.Nil(tasks)
+	assert.EqualError(err, "received error when invoking DescribeTasks: unable to describe task arn-2: MISSING")
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+}
+
+func TestDescribeTasksFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	api := &ecsAPIFake{
+		describeTasks: []*ecs.DescribeTasksOutput{
+			{
+				Tasks:    []*ecs.Task{describedTask("arn-1", "RUNNING")},
+				Failures: []*ecs.Failure{{Arn: aws.String("arn-2"), Reason: aws.String("MISSING")}},
+			},
+			{Failures: []*ecs.Failure{{Arn: aws.String("arn-3"), Reason: aws.String("ACCESS_DENIED")}}},
+		},
+	}
+	client := newClient(api, &RunConfig{Cluster: "cluster"})
+
+	// The tasks that were found come back along with the missing ones.
+	tasks, err := client.DescribeTasks([]*string{aws.String("arn-1"), aws.String("arn-2")})
+
+	assert.Len(tasks, 1)
+	assert.True(isMissingTasks(err))
+	assert.EqualError(err, "received error when invoking DescribeTasks: unable to describe task arn-2: MISSING")
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+
+	tasks, err = client.DescribeTasks([]*string{aws.String("arn-3")})
+
+	assert.Nil(tasks)
+	assert.False(isMissingTasks(err))
+	assert.EqualError(err, "received error when invoking DescribeTasks: unable to describe task arn-3: ACCESS_DENIED")
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+}
+
+func TestListTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	fake := &ecsAPIFake{listTasksPages: [][]*string{
+		{aws.String("arn:1"), aws.String("arn:2")},
+		{aws.String("arn:3")},
+	}}
+	taskArns, err := newClient(fake, &RunConfig{Cluster: "cluster"}).ListTasks(ecs.DesiredStatusRunning)
+
+	assert.Nil(err)
+	assert.Equal(aws.StringSlice([]string{"arn:1", "arn:2", "arn:3"}), taskArns)
+}
+
+func TestDescribeTasksBatches(t *testing.T) {
+	assert := assert.New(t)
+
+	taskArns := []*string{}
+	for i := 0; i < 150; i++ {
+		taskArns = append(taskArns, aws.String(fmt.Sprintf("arn:%d", i)))
+	}
+
+	fake := &ecsAPIFake{describeTasks: []*ecs.DescribeTasksOutput{
+		{Tasks: []*ecs.Task{describedTask("arn:0", "RUNNING")}},
+		{Tasks: []*ecs.Task{describedTask("arn:100", "STOPPED")}},
+	}}
+	tasks, err := newClient(fake, &RunConfig{Cluster: "cluster"}).DescribeTasks(taskArns)
+
+	assert.Nil(err)
+	assert.Len(tasks, 2)
+	assert.Len(fake.describeTasksInputs, 2)
+	assert.Len(fake.describeTasksInputs[0].Tasks, 100)
+	assert.Len(fake.describeTasksInputs[1].Tasks, 50)
+	assert.Equal([]*string{aws.String("TAGS")}, fake.describeTasksInputs[0].Include)
+}
+
+func TestBuildRunTaskInput(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:          "cluster",
+		TaskDefinition:   "task:3",
+		ContainerName:    "app",
+		LaunchType:       "FARGATE",
+		Count:            1,
+		Command:          []*string{aws.String("echo"), aws.String("hi")},
+		SubnetIDs:        []string{"subnet-1"},
+		SecurityGroupIDs: []string{"sg-1"},
+		AssignPublicIP:   ecs.AssignPublicIpDisabled,
+		NetworkMode:      ecs.NetworkModeAwsvpc,
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Equal([]*string{aws.String("subnet-1")}, input.NetworkConfiguration.AwsvpcConfiguration.Subnets)
+	assert.Equal([]*string{aws.String("sg-1")}, input.NetworkConfiguration.AwsvpcConfiguration.SecurityGroups)
+
+	override := input.Overrides.ContainerOverrides[0]
+	assert.Equal("app", *override.Name)
+	assert.Equal(config.Command, override.Command)
+	assert.Nil(override.Environment)
+	assert.Nil(override.EnvironmentFiles)
+
+	config.Environment = map[string]string{"RUN_COMMAND_TASK": "true"}
+	config.EnvironmentFiles = []string{"arn:aws:s3:::bucket/app.env"}
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	override = input.Overrides.ContainerOverrides[0]
+	assert.Equal([]*ecs.KeyValuePair{{Name: aws.String("RUN_COMMAND_TASK"), Value: aws.String("true")}}, override.Environment)
+	assert.Equal([]*ecs.EnvironmentFile{{Type: aws.String("s3"), Value: aws.String("arn:aws:s3:::bucket/app.env")}}, override.EnvironmentFiles)
+}
+
+func TestBuildRunTaskInputBridgeNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:        "cluster",
+		TaskDefinition: "task",
+		ContainerName:  "task",
+		LaunchType:     ecs.LaunchTypeEc2,
+		NetworkMode:    ecs.NetworkModeBridge,
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Nil(input.NetworkConfiguration)
+	assert.Equal("EC2", *input.LaunchType)
+}
+
+func TestBuildRunTaskInputResources(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:        "cluster",
+		TaskDefinition: "task",
+		ContainerName:  "task",
+		LaunchType:     ecs.LaunchTypeEc2,
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Nil(input.Overrides.Cpu)
+	assert.Nil(input.Overrides.Memory)
+	assert.Nil(input.Overrides.ContainerOverrides[0].Cpu)
+	assert.Nil(input.Overrides.EphemeralStorage)
+
+	config.CPU = 4096
+	config.Memory = 16384
+	config.ContainerCPU = 2048
+	config.ContainerMemory = 8192
+	config.MemoryReservation = 4096
+	config.EphemeralStorage = 100
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Equal("4096", *input.Overrides.Cpu)
+	assert.Equal(int64(100), *input.Overrides.EphemeralStorage.SizeInGiB)
+	assert.Equal("16384", *input.Overrides.Memory)
+
+	override := input.Overrides.ContainerOverrides[0]
+	assert.Equal(int64(2048), *override.Cpu)
+	assert.Equal(int64(8192), *override.Memory)
+	assert.Equal(int64(4096), *override.MemoryReservation)
+}
+
+func TestBuildRunTaskInputRoles(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:        "cluster",
+		TaskDefinition: "task",
+		ContainerName:  "task",
+		LaunchType:     ecs.LaunchTypeEc2,
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Nil(input.Overrides.TaskRoleArn)
+	assert.Nil(input.Overrides.ExecutionRoleArn)
+
+	config.TaskRoleArn = "arn:aws:iam::123456789012:role/data-import"
+	config.ExecutionRoleArn = "arn:aws:iam::123456789012:role/ecs-execution"
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Equal(config.TaskRoleArn, *input.Overrides.TaskRoleArn)
+	assert.Equal(config.ExecutionRoleArn, *input.Overrides.ExecutionRoleArn)
+}
+
+func TestBuildRunTaskInputTags(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{
+		Cluster:        "cluster",
+		TaskDefinition: "task",
+		ContainerName:  "task",
+		LaunchType:     ecs.LaunchTypeEc2,
+	}
+	input := newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Nil(input.Tags)
+	assert.Nil(input.StartedBy)
+	assert.Nil(input.Group)
+	assert.Nil(input.PropagateTags)
+
+	config.RunInfo = testRunInfo()
+	config.Tags = map[string]string{"team": "data"}
+	config.PropagateTags = "TASK_DEFINITION"
+	config.Environment = map[string]string{"ECSRUN_CONFIG_ENTRY": "custom"}
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+
+	assert.Len(input.Tags, 6)
+	assert.Equal("matt_example_com", *input.StartedBy)
+	assert.Equal("ecsrun:migrate", *input.Group)
+	assert.Equal("TASK_DEFINITION", *input.PropagateTags)
+
+	// The user's env vars win over ours.
+	env := input.Overrides.ContainerOverrides[0].Environment
+	assert.Len(env, 5)
+	assert.Equal(&ecs.KeyValuePair{Name: aws.String("ECSRUN_CONFIG_ENTRY"), Value: aws.String("custom")}, env[1])
+
+	// NONE is the default so there's nothing to send.
+	config.PropagateTags = "NONE"
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+	assert.Nil(input.PropagateTags)
+
+	// --no-tags leaves out our tags but not the ones that were asked for.
+	config.NoTags = true
+	input = newClient(&ecsAPIFake{}, config).BuildRunTaskInput()
+	assert.Equal([]*ecs.Tag{{Key: aws.String("team"), Value: aws.String("data")}}, input.Tags)
+	assert.Equal("matt_example_com", *input.StartedBy)
+}
+
+func TestRunTaskTagAccessDenied(t *testing.T) {
+	assert := assert.New(t)
+
+	denied := awserr.New(ecs.ErrCodeAccessDeniedException, "not authorized to perform: ecs:TagResource", nil)
+	client := newClient(&ecsAPIFake{runTaskErr: denied}, &RunConfig{})
+
+	_, err := client.RunTask(&ecs.RunTaskInput{Tags: []*ecs.Tag{{Key: aws.String(tagRunID), Value: aws.String("abc")}}})
+	assert.IsType(&APIError{}, err)
+	assert.Contains(err.Error(), "Pass --no-tags to launch it without the ecsrun tags")
+	assert.True(errors.Is(err, denied))
+
+	// Without any tags the permission is missing for some other reason.
+	_, err = client.RunTask(&ecs.RunTaskInput{})
+	assert.NotContains(err.Error(), "--no-tags")
+}
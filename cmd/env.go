@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// initEnvironment merges the environment variables for the container from all
+// of their sources and stores the result in viper as "environment". Later
+// sources override earlier ones:
+//
+//  1. env-file entries in the config file
+//  2. the env map in the config file
+//  3. --env-file flags
+//  4. --env flags
+//
+// S3 hosted env files from the config file and --env-s3-file are stored as
+// "environment-files".
+func initEnvironment(flags *pflag.FlagSet) error {
+	env := make(map[string]string)
+
+	if err := mergeEnvFiles(env, viper.GetStringSlice("env-file")); err != nil {
+		return err
+	}
+
+	for name, value := range viper.GetStringMapString("env") {
+		env[name] = value
+	}
+
+	cliEnvFiles, _ := flags.GetStringArray("env-file")
+	if err := mergeEnvFiles(env, cliEnvFiles); err != nil {
+		return err
+	}
+
+	cliEnv, _ := flags.GetStringArray("env")
+	for _, envVar := range cliEnv {
+		name, value, err := parseEnvVar(envVar)
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+
+		env[name] = value
+	}
+
+	for name := range env {
+		if !envNameRegex.MatchString(name) {
+			return &ConfigError{Err: fmt.Errorf("invalid environment variable name %q", name)}
+		}
+	}
+
+	s3Files := viper.GetStringSlice("env-s3-file")
+	cliS3Files, _ := flags.GetStringArray("env-s3-file")
+	s3Files = append(s3Files, cliS3Files...)
+	for _, arn := range s3Files {
+		if !strings.HasPrefix(arn, "arn:") || !strings.Contains(arn, ":s3:::") {
+			return &ConfigError{Err: fmt.Errorf("env-s3-file %q must be an S3 object ARN, e.g. arn:aws:s3:::bucket/app.env", arn)}
+		}
+	}
+
+	viper.Set("environment", env)
+	viper.Set("environment-files", s3Files)
+
+	return nil
+}
+
+// mergeEnvFiles reads each of the given dotenv files into env.
+func mergeEnvFiles(env map[string]string, filenames []string) error {
+	for _, filename := range filenames {
+		contents, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+
+		fileEnv, err := parseDotenv(filename, string(contents))
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+
+		for name, value := range fileEnv {
+			env[name] = value
+		}
+	}
+
+	return nil
+}
+
+// parseEnvVar splits a KEY=VALUE string into its name and value.
+func parseEnvVar(envVar string) (string, string, error) {
+	parts := strings.SplitN(envVar, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid env %q, expected KEY=VALUE", envVar)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// parseDotenv parses the contents of a dotenv file. It supports blank lines,
+// # comments, an optional `export` prefix, unquoted values with trailing
+// comments, 'single quoted' literal values, and "double quoted" values which
+// may contain \n, \t, \", and \\ escapes and span multiple lines.
+func parseDotenv(filename, contents string) (map[string]string, error) {
+	env := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		startLine := lineNum
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		name, rawValue, err := parseEnvVar(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, startLine, err)
+		}
+
+		name = strings.TrimSpace(name)
+		rawValue = strings.TrimSpace(rawValue)
+
+		// Double quoted values can span multiple lines so keep reading until
+		// we find the closing quote.
+		if strings.HasPrefix(rawValue, `"`) {
+			for !hasClosingQuote(rawValue) && scanner.Scan() {
+				lineNum++
+				rawValue += "\n" + scanner.Text()
+			}
+		}
+
+		value, err := parseDotenvValue(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, startLine, err)
+		}
+
+		env[name] = value
+	}
+
+	return env, scanner.Err()
+}
+
+// hasClosingQuote reports whether the given double quoted value has an
+// unescaped closing quote.
+func hasClosingQuote(value string) bool {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDotenvValue unquotes a single dotenv value.
+func parseDotenvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end == -1 {
+			return "", errors.New("unterminated single quote")
+		}
+
+		return raw[1 : end+1], nil
+
+	case strings.HasPrefix(raw, `"`):
+		var sb strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch raw[i] {
+			case '"':
+				return sb.String(), nil
+			case '\\':
+				i++
+				if i == len(raw) {
+					continue
+				}
+
+				switch raw[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				default:
+					sb.WriteByte(raw[i])
+				}
+			default:
+				sb.WriteByte(raw[i])
+			}
+		}
+
+		return "", errors.New("unterminated double quote")
+
+	default:
+		// Unquoted values can have a trailing comment.
+		if idx := strings.Index(raw, " #"); idx != -1 {
+			raw = raw[:idx]
+		}
+
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// getKeyValuePairs converts the given environment to the sorted list of pairs
+// that ECS expects.
+func getKeyValuePairs(env map[string]string) []*ecs.KeyValuePair {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []*ecs.KeyValuePair{}
+	for _, name := range names {
+		result = append(result, &ecs.KeyValuePair{
+			Name:  aws.String(name),
+			Value: aws.String(env[name]),
+		})
+	}
+
+	return result
+}
+
+// getEnvironmentFiles converts the given S3 object ARNs to ECS EnvironmentFiles.
+func getEnvironmentFiles(arns []string) []*ecs.EnvironmentFile {
+	result := []*ecs.EnvironmentFile{}
+	for _, arn := range arns {
+		result = append(result, &ecs.EnvironmentFile{
+			Type:  aws.String(ecs.EnvironmentFileTypeS3),
+			Value: aws.String(arn),
+		})
+	}
+
+	return result
+}
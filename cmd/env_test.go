@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+func newEnvFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringArray("env", []string{}, "")
+	flags.StringArray("env-file", []string{}, "")
+	flags.StringArray("env-s3-file", []string{}, "")
+	return flags
+}
+
+// Tests
+/////////
+
+func TestParseDotenv(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := `# A comment
+PLAIN=value
+export EXPORTED=yes
+  SPACED = padded value  
+INLINE=value # a comment
+HASH=value#not-a-comment
+SINGLE='literal $HOME \n # not a comment'
+DOUBLE="line one\nline two \"quoted\""
+MULTI="first
+second"
+EMPTY=
+EQUALS=a=b
+`
+
+	env, err := parseDotenv(".env", contents)
+
+	assert.Nil(err)
+	assert.Equal(map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"SPACED":   "padded value",
+		"INLINE":   "value",
+		"HASH":     "value#not-a-comment",
+		"SINGLE":   `literal $HOME \n # not a comment`,
+		"DOUBLE":   "line one\nline two \"quoted\"",
+		"MULTI":    "first\nsecond",
+		"EMPTY":    "",
+		"EQUALS":   "a=b",
+	}, env)
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseDotenv(".env", "GOOD=1\nNOT A VAR\n")
+	assert.EqualError(err, `.env:2: invalid env "NOT A VAR", expected KEY=VALUE`)
+
+	_, err = parseDotenv(".env", "GOOD=1\nBAD='unterminated\n")
+	assert.EqualError(err, ".env:2: unterminated single quote")
+
+	_, err = parseDotenv(".env", "BAD=\"unterminated\nOTHER=1\n")
+	assert.EqualError(err, ".env:1: unterminated double quote")
+}
+
+func TestInitEnvironment(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	previousFs := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = previousFs }()
+
+	afero.WriteFile(fs, "config.env", []byte("FROM=config-file\nCONFIG_FILE=1\n"), 0644)
+	afero.WriteFile(fs, "cli.env", []byte("FROM=cli-file\nCLI_FILE=1\n"), 0644)
+
+	viper.MergeConfigMap(map[string]interface{}{
+		"env-file":    []string{"config.env"},
+		"env":         map[string]string{"FROM": "config-env", "CONFIG_ENV": "1", "MixedCase": "kept"},
+		"env-s3-file": []string{"arn:aws:s3:::bucket/config.env"},
+	})
+
+	flags := newEnvFlags()
+	flags.Set("env-file", "cli.env")
+	flags.Set("env", "FROM=cli-env")
+	flags.Set("env", "CLI_ENV=a,b=c")
+	flags.Set("env-s3-file", "arn:aws:s3:::bucket/cli.env")
+
+	err := initEnvironment(flags)
+
+	assert.Nil(err)
+	assert.Equal(map[string]string{
+		"FROM":        "cli-env",
+		"CONFIG_FILE": "1",
+		"CONFIG_ENV":  "1",
+		"MixedCase":   "kept",
+		"CLI_FILE":    "1",
+		"CLI_ENV":     "a,b=c",
+	}, viper.GetStringMapString("environment"))
+	assert.Equal([]string{"arn:aws:s3:::bucket/config.env", "arn:aws:s3:::bucket/cli.env"}, viper.GetStringSlice("environment-files"))
+}
+
+func TestInitEnvironmentErrors(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	flags := newEnvFlags()
+	flags.Set("env", "NO_EQUALS")
+	assert.IsType(&ConfigError{}, initEnvironment(flags))
+
+	flags = newEnvFlags()
+	flags.Set("env", "BAD-NAME=1")
+	assert.IsType(&ConfigError{}, initEnvironment(flags))
+
+	flags = newEnvFlags()
+	flags.Set("env-file", "does-not-exist.env")
+	assert.IsType(&ConfigError{}, initEnvironment(flags))
+
+	flags = newEnvFlags()
+	flags.Set("env-s3-file", "s3://bucket/app.env")
+	assert.IsType(&ConfigError{}, initEnvironment(flags))
+}
+
+func TestGetKeyValuePairs(t *testing.T) {
+	assert := assert.New(t)
+
+	actual := getKeyValuePairs(map[string]string{"B": "2", "A": "1"})
+
+	assert.Equal([]*ecs.KeyValuePair{
+		{Name: aws.String("A"), Value: aws.String("1")},
+		{Name: aws.String("B"), Value: aws.String("2")},
+	}, actual)
+}
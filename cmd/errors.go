@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Exit statuses returned by ecsrun. These are part of ecsrun's interface so
+// scripts can rely on them: don't renumber existing entries. When running with
+// --wait a task's non-zero container exit code is passed through as is.
+const (
+	ExitCodeOK           = 0
+	ExitCodeError        = 1
+	ExitCodeConfig       = 64
+	ExitCodeCredentials  = 65
+	ExitCodeAPI          = 66
+	ExitCodeTaskFailed   = 67
+	ExitCodePartialStart = 68
+	ExitCodeNoExitCode   = 69
+	ExitCodeNotStarted   = 70
+	ExitCodeNotFound     = 71
+	ExitCodeTimeout      = 124
+	ExitCodeInterrupted  = 130
+)
+
+// exitCoder is implemented by errors that know which exit status ecsrun
+// should use when they cause it to stop.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// ConfigError is returned when ecsrun's flags, env vars, or config file are invalid.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// ExitCode returns ExitCodeConfig.
+func (e *ConfigError) ExitCode() int { return ExitCodeConfig }
+
+// CredentialError is returned when we're unable to set up an AWS session.
+type CredentialError struct {
+	Err error
+}
+
+func (e *CredentialError) Error() string {
+	return fmt.Sprintf("unable to init AWS session, check your credentials and profile: %s", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CredentialError) Unwrap() error { return e.Err }
+
+// ExitCode returns ExitCodeCredentials.
+func (e *CredentialError) ExitCode() int { return ExitCodeCredentials }
+
+// APIError is returned when a call to the AWS API fails.
+type APIError struct {
+	Op  string
+	Err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("received error when invoking %s: %s", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *APIError) Unwrap() error { return e.Err }
+
+// ExitCode returns ExitCodeAPI.
+func (e *APIError) ExitCode() int { return ExitCodeAPI }
+
+// TaskFailureError is returned when a task we launched didn't succeed. Code is
+// either the container's non-zero exit code or one of ecsrun's exit statuses.
+type TaskFailureError struct {
+	TaskArn string
+	Code    int
+	Reason  string
+}
+
+func (e *TaskFailureError) Error() string {
+	if e.TaskArn == "" {
+		return e.Reason
+	}
+
+	return fmt.Sprintf("task %s failed: %s", e.TaskArn, e.Reason)
+}
+
+// ExitCode returns the exit status of the failed task.
+func (e *TaskFailureError) ExitCode() int { return e.Code }
+
+// TimeoutError is returned when a task doesn't finish within its deadline.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ExitCode returns ExitCodeTimeout.
+func (e *TimeoutError) ExitCode() int { return ExitCodeTimeout }
+
+// NotFoundError is returned when nothing matches the tasks, run, or logs we
+// were asked to work with.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// ExitCode returns ExitCodeNotFound.
+func (e *NotFoundError) ExitCode() int { return ExitCodeNotFound }
+
+// InterruptError is returned when ecsrun is interrupted while following or
+// waiting on tasks, after either stopping them or detaching from them, or when
+// there were no tasks to do either with.
+type InterruptError struct {
+	Action   string
+	TaskArns []string
+}
+
+func (e *InterruptError) Error() string {
+	switch e.Action {
+	case interruptAbort:
+		return "interrupted while no task(s) were running, nothing was stopped"
+	case interruptStop:
+		return fmt.Sprintf("interrupted, stopped task(s): %s", strings.Join(e.TaskArns, ", "))
+	default:
+		return fmt.Sprintf("detached, task(s) still running: %s", strings.Join(e.TaskArns, ", "))
+	}
+}
+
+// ExitCode returns ExitCodeInterrupted.
+func (e *InterruptError) ExitCode() int { return ExitCodeInterrupted }
+
+// ExitCode maps the given error to the exit status ecsrun should use for it.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+
+	var coder exitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	return ExitCodeError
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	cause := errors.New("boom")
+
+	assert.Equal(ExitCodeOK, ExitCode(nil))
+	assert.Equal(ExitCodeError, ExitCode(cause))
+	assert.Equal(ExitCodeConfig, ExitCode(&ConfigError{Err: cause}))
+	assert.Equal(ExitCodeCredentials, ExitCode(&CredentialError{Err: cause}))
+	assert.Equal(ExitCodeAPI, ExitCode(&APIError{Op: "RunTask", Err: cause}))
+	assert.Equal(ExitCodeTimeout, ExitCode(&TimeoutError{Err: cause}))
+	assert.Equal(ExitCodeNotFound, ExitCode(&NotFoundError{Err: cause}))
+	assert.Equal(3, ExitCode(&TaskFailureError{Code: 3}))
+	assert.Equal(ExitCodeTaskFailed, ExitCode(&runTaskFailuresError{Requested: 1}))
+	assert.Equal(ExitCodePartialStart, ExitCode(&runTaskFailuresError{Started: 1, Requested: 2}))
+
+	// Wrapped errors keep their exit code.
+	wrapped := fmt.Errorf("while waiting: %w", &APIError{Op: "DescribeTasks", Err: cause})
+	assert.Equal(ExitCodeAPI, ExitCode(wrapped))
+}
+
+func TestErrorMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	cause := errors.New("boom")
+
+	assert.Equal("boom", (&ConfigError{Err: cause}).Error())
+	assert.Equal("received error when invoking RunTask: boom", (&APIError{Op: "RunTask", Err: cause}).Error())
+	assert.Contains((&CredentialError{Err: cause}).Error(), "check your credentials and profile: boom")
+	assert.Equal("task arn-1 failed: container app exited with code 2", (&TaskFailureError{
+		TaskArn: "arn-1",
+		Code:    2,
+		Reason:  "container app exited with code 2",
+	}).Error())
+
+	assert.True(errors.Is(&APIError{Op: "RunTask", Err: cause}, cause))
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// failureReasons explains the reason codes that ECS returns in
+// RunTaskOutput.Failures. Reasons are matched on their prefix so that codes
+// which carry extra detail (e.g. "ATTRIBUTE ...") still get explained.
+var failureReasons = map[string]string{
+	"AGENT":                   "The container instance's ECS agent is disconnected or out of date. Check that the agent is running and can reach the ECS service.",
+	"ATTRIBUTE":               "No container instance in the cluster has an attribute required by the task definition. Check the task definition's placement constraints and requiresAttributes.",
+	"EMPTY CAPACITY":          "The capacity provider has no capacity to place the task on. Check that its Auto Scaling group has instances registered to the cluster.",
+	"LOCATION":                "No container instance is in the subnet or availability zone required by the task's placement constraints.",
+	"MISSING":                 "The cluster or container instance could not be found. Check that the cluster name and region are correct.",
+	"RESOURCE:CPU":            "No container instance in the cluster has enough free CPU to run the task. Scale up the cluster or reduce the task's CPU.",
+	"RESOURCE:ENI":            "No container instance has a free elastic network interface for an awsvpc task. Scale up the cluster or enable ENI trunking.",
+	"RESOURCE:GPU":            "No container instance in the cluster has enough free GPUs to run the task.",
+	"RESOURCE:MEMORY":         "No container instance in the cluster has enough free memory to run the task. Scale up the cluster or reduce the task's memory.",
+	"RESOURCE:PORTS":          "A port the task needs is already in use on every container instance. Use dynamic host ports or scale up the cluster.",
+	"Capacity is unavailable": "Fargate does not have capacity for the task in the requested availability zones right now. Try again later or add subnets in other availability zones.",
+}
+
+// explainFailure turns a single RunTask failure into a human readable message.
+func explainFailure(failure *ecs.Failure) string {
+	reason := aws.StringValue(failure.Reason)
+	explanation := "Unknown failure reason. See the ECS API failure reasons documentation for details."
+
+	// Check the longest prefixes first so RESOURCE:PORTS_TCP doesn't match something shorter.
+	prefixes := make([]string, 0, len(failureReasons))
+	for prefix := range failureReasons {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(reason, prefix) {
+			explanation = failureReasons[prefix]
+			break
+		}
+	}
+
+	msg := fmt.Sprintf("%s (%s): %s", reason, aws.StringValue(failure.Arn), explanation)
+	if failure.Detail != nil {
+		msg = fmt.Sprintf("%s Detail: %s", msg, aws.StringValue(failure.Detail))
+	}
+
+	return msg
+}
+
+// runTaskFailuresError is returned by RunTask when ECS reports that it couldn't
+// start all of the requested tasks.
+type runTaskFailuresError struct {
+	Failures  []*ecs.Failure
+	Started   int64
+	Requested int64
+}
+
+func (e *runTaskFailuresError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Started %d of %d requested task(s).\n", e.Started, e.Requested)
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&sb, "  - %s\n", explainFailure(failure))
+	}
+
+	return sb.String()
+}
+
+// Partial reports whether some, but not all, of the requested tasks started.
+func (e *runTaskFailuresError) Partial() bool {
+	return e.Started > 0
+}
+
+// ExitCode returns the exit status ecsrun should use for these failures.
+func (e *runTaskFailuresError) ExitCode() int {
+	if e.Partial() {
+		return ExitCodePartialStart
+	}
+
+	return ExitCodeTaskFailed
+}
+
+// checkRunTaskFailures returns a runTaskFailuresError if the given output
+// didn't start as many tasks as the given input asked for.
+func checkRunTaskFailures(input *ecs.RunTaskInput, output *ecs.RunTaskOutput) error {
+	requested := aws.Int64Value(input.Count)
+	if requested == 0 {
+		requested = 1
+	}
+
+	started := int64(len(output.Tasks))
+	if len(output.Failures) == 0 && started >= requested {
+		return nil
+	}
+
+	return &runTaskFailuresError{
+		Failures:  output.Failures,
+		Started:   started,
+		Requested: requested,
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	memory := explainFailure(&ecs.Failure{
+		Arn:    aws.String("arn:aws:ecs:us-east-1:123456789012:container-instance/abc"),
+		Reason: aws.String("RESOURCE:MEMORY"),
+	})
+	assert.Contains(memory, "RESOURCE:MEMORY (arn:aws:ecs:us-east-1:123456789012:container-instance/abc)")
+	assert.Contains(memory, "enough free memory")
+
+	ports := explainFailure(&ecs.Failure{Reason: aws.String("RESOURCE:PORTS_TCP")})
+	assert.Contains(ports, "port the task needs")
+
+	missing := explainFailure(&ecs.Failure{
+		Arn:    aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/nope"),
+		Reason: aws.String("MISSING"),
+		Detail: aws.String("cluster not found"),
+	})
+	assert.Contains(missing, "cluster name and region")
+	assert.Contains(missing, "Detail: cluster not found")
+
+	unknown := explainFailure(&ecs.Failure{Reason: aws.String("SOMETHING NEW")})
+	assert.Contains(unknown, "Unknown failure reason")
+}
+
+func TestCheckRunTaskFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	input := &ecs.RunTaskInput{Count: aws.Int64(2)}
+	failure := &ecs.Failure{Reason: aws.String("RESOURCE:CPU")}
+
+	success := &ecs.RunTaskOutput{Tasks: []*ecs.Task{{}, {}}}
+	assert.Nil(checkRunTaskFailures(input, success))
+
+	partial := &ecs.RunTaskOutput{Tasks: []*ecs.Task{{}}, Failures: []*ecs.Failure{failure}}
+	err := checkRunTaskFailures(input, partial).(*runTaskFailuresError)
+	assert.True(err.Partial())
+	assert.Equal(ExitCodePartialStart, err.ExitCode())
+	assert.Contains(err.Error(), "Started 1 of 2 requested task(s).")
+
+	failed := &ecs.RunTaskOutput{Failures: []*ecs.Failure{failure}}
+	err = checkRunTaskFailures(input, failed).(*runTaskFailuresError)
+	assert.False(err.Partial())
+	assert.Equal(ExitCodeTaskFailed, err.ExitCode())
+	assert.Contains(err.Error(), "RESOURCE:CPU")
+
+	// No failures but no tasks either is still a failure.
+	empty := &ecs.RunTaskOutput{}
+	err = checkRunTaskFailures(&ecs.RunTaskInput{}, empty).(*runTaskFailuresError)
+	assert.Equal(int64(1), err.Requested)
+	assert.Equal(ExitCodeTaskFailed, err.ExitCode())
+}
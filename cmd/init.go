@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -10,15 +9,15 @@ import (
 var InitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Creates a blank `ecsrun.yml` config file in the current directory.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Reset viper as it carries over config from root. We want to build our own config.
 		viper.Reset()
 
-		initCmd()
+		return initCmd()
 	},
 }
 
-func initCmd() {
+func initCmd() error {
 
 	config := make(map[string]interface{})
 	config["default"] = map[string]interface{}{
@@ -30,13 +29,13 @@ func initCmd() {
 	}
 
 	if err := viper.MergeConfigMap(config); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// Write the file.
 	if err := viper.SafeWriteConfigAs("./ecsrun.yaml"); err != nil {
-		log.Fatal(err)
-		red := color.New(color.FgRed)
-		red.Printf("ecsrun.yaml already exists.\n")
+		return &ConfigError{Err: err}
 	}
+
+	return nil
 }
@@ -25,7 +25,7 @@ func TestInitCmd(t *testing.T) {
 
 	viper.SetFs(testFs)
 
-	initCmd()
+	assert.Nil(initCmd())
 
 	exists, err = afero.Exists(testFs, "./ecsrun.yaml")
 	if err != nil {
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// What to do with the tasks we're waiting on when ecsrun is interrupted.
+// interruptAbort isn't a setting, it's what we did when there were no tasks
+// to stop or detach from.
+const (
+	interruptAsk    = "ask"
+	interruptStop   = "stop"
+	interruptDetach = "detach"
+	interruptAbort  = "abort"
+)
+
+// These are swapped out in tests so they can send their own signals and
+// force quit without exiting the test binary.
+var (
+	notifySignals = func(signals chan<- os.Signal) { signal.Notify(signals, os.Interrupt, syscall.SIGTERM) }
+	stopSignals   = func(signals chan<- os.Signal) { signal.Stop(signals) }
+	exit          = os.Exit
+)
+
+// validateOnInterrupt checks the on-interrupt setting is one we know.
+func validateOnInterrupt(onInterrupt string) error {
+	switch onInterrupt {
+	case "", interruptAsk, interruptStop, interruptDetach:
+		return nil
+	default:
+		return &ConfigError{Err: fmt.Errorf("invalid on-interrupt %q, expected ask, stop, or detach", onInterrupt)}
+	}
+}
+
+// interrupter handles SIGINT / SIGTERM while we're following or waiting on
+// tasks so that Ctrl-C doesn't just leave them running without a word. The
+// first signal stops the tasks or detaches from them, depending on the
+// config's on-interrupt setting, and a second one force quits.
+type interrupter struct {
+	client ECSClient
+	config *RunConfig
+
+	mu     sync.Mutex
+	action string
+}
+
+// watch handles signals for the given tasks until the returned func is called.
+// The returned context is cancelled if we detach from the tasks.
+func (i *interrupter) watch(taskArns []*string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	notifySignals(signals)
+
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				if interrupted {
+					red.Fprintln(os.Stderr, "Force quitting, the task(s) are left as they are.")
+					exit(ExitCodeInterrupted)
+					return
+				}
+
+				interrupted = true
+				log.Warn("Received ", sig, ". Press Ctrl-C again to force quit.")
+				go i.handle(taskArns, cancel)
+			}
+		}
+	}()
+
+	return ctx, func() {
+		stopSignals(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// handle carries out the on-interrupt action, asking for it if need be.
+func (i *interrupter) handle(taskArns []*string, cancel context.CancelFunc) {
+	// Without any tasks there's nothing to stop or detach from, so we just
+	// stop what we're doing.
+	if len(taskArns) == 0 {
+		i.mu.Lock()
+		i.action = interruptAbort
+		i.mu.Unlock()
+
+		cancel()
+		return
+	}
+
+	action := i.config.OnInterrupt
+	if action == "" || action == interruptAsk {
+		action = askInterruptAction()
+	}
+
+	i.mu.Lock()
+	i.action = action
+	i.mu.Unlock()
+
+	if action == interruptDetach {
+		cancel()
+		return
+	}
+
+	reason := getStopReason(i.config)
+	for _, taskArn := range taskArns {
+		if _, err := i.client.StopTask(aws.StringValue(taskArn), reason); err != nil {
+			log.Error("Unable to stop task ", aws.StringValue(taskArn), ". ", err)
+		}
+	}
+
+	log.Warn("Stopping the task(s) and waiting for them to stop. Press Ctrl-C again to force quit.")
+}
+
+// interrupted returns the action taken if we were interrupted, otherwise an
+// empty string.
+func (i *interrupter) interrupted() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.action
+}
+
+// askInterruptAction asks whether to stop the tasks or detach from them. Only
+// an explicit answer stops them, so a migration is never stopped halfway
+// without confirmation.
+func askInterruptAction() string {
+	fmt.Fprint(os.Stderr, "Stop the task(s) and wait for them [s], or detach and leave them running [d]? ")
+
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "s" || answer == interruptStop {
+		return interruptStop
+	}
+
+	return interruptDetach
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+// useSignals captures the channel the interrupter listens on so tests can
+// send their own signals, and records the exit code of a force quit.
+func useSignals() (*chan<- os.Signal, chan int, func()) {
+	var signals chan<- os.Signal
+	exited := make(chan int, 1)
+
+	previousNotifySignals, previousStopSignals, previousExit := notifySignals, stopSignals, exit
+	notifySignals = func(ch chan<- os.Signal) { signals = ch }
+	stopSignals = func(ch chan<- os.Signal) {}
+	exit = func(code int) { exited <- code }
+
+	return &signals, exited, func() {
+		notifySignals, stopSignals, exit = previousNotifySignals, previousStopSignals, previousExit
+	}
+}
+
+// Tests
+/////////
+
+func TestValidateOnInterrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, valid := range []string{"", interruptAsk, interruptStop, interruptDetach} {
+		assert.Nil(validateOnInterrupt(valid), valid)
+	}
+
+	err := validateOnInterrupt("kill")
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "invalid on-interrupt \"kill\"")
+
+	// Aborting is only what we do when there's nothing to stop.
+	assert.IsType(&ConfigError{}, validateOnInterrupt(interruptAbort))
+}
+
+func TestInterrupterHandle(t *testing.T) {
+	assert := assert.New(t)
+	defer useStsAPIFake(&stsAPIFake{})()
+
+	arns := []*string{aws.String("arn:aws:ecs:us-east-1:123456789012:task/cluster/aaa")}
+
+	// Stopping stops every task and leaves the context alone so we keep
+	// waiting for them to reach STOPPED.
+	client := &stopEcsClient{}
+	i := &interrupter{client: client, config: &RunConfig{OnInterrupt: interruptStop}}
+	cancelled := false
+	i.handle(arns, func() { cancelled = true })
+
+	assert.Equal(interruptStop, i.interrupted())
+	assert.Equal([]string{"arn:aws:ecs:us-east-1:123456789012:task/cluster/aaa"}, client.stopped)
+	assert.Contains(client.reasons[0], "Stopped with ecsrun by")
+	assert.False(cancelled)
+
+	// Detaching cancels the context without stopping anything.
+	client = &stopEcsClient{}
+	i = &interrupter{client: client, config: &RunConfig{OnInterrupt: interruptDetach}}
+	i.handle(arns, func() { cancelled = true })
+
+	assert.Equal(interruptDetach, i.interrupted())
+	assert.Empty(client.stopped)
+	assert.True(cancelled)
+
+	// Without any tasks, e.g. between retries, we just give up without asking.
+	client = &stopEcsClient{}
+	i = &interrupter{client: client, config: &RunConfig{OnInterrupt: interruptAsk}}
+	cancelled = false
+	i.handle(nil, func() { cancelled = true })
+
+	assert.Equal(interruptAbort, i.interrupted())
+	assert.Empty(client.stopped)
+	assert.True(cancelled)
+}
+
+func TestInterrupterWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	signals, exited, restore := useSignals()
+	defer restore()
+
+	i := &interrupter{client: &stopEcsClient{}, config: &RunConfig{OnInterrupt: interruptDetach}}
+	ctx, stopWatching := i.watch([]*string{aws.String("aaa")})
+	defer stopWatching()
+
+	assert.Equal("", i.interrupted())
+
+	*signals <- os.Interrupt
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		assert.Fail("context was not cancelled after detaching")
+	}
+	assert.Equal(interruptDetach, i.interrupted())
+
+	// A second signal force quits.
+	*signals <- os.Interrupt
+	select {
+	case code := <-exited:
+		assert.Equal(ExitCodeInterrupted, code)
+	case <-time.After(time.Second):
+		assert.Fail("did not force quit on the second signal")
+	}
+}
+
+func TestAskInterruptAction(t *testing.T) {
+	assert := assert.New(t)
+
+	previousStdin := stdin
+	defer func() { stdin = previousStdin }()
+
+	// Only an explicit answer stops the tasks.
+	for answer, expected := range map[string]string{"s\n": interruptStop, "STOP\n": interruptStop, "d\n": interruptDetach, "\n": interruptDetach, "": interruptDetach} {
+		stdin = strings.NewReader(answer)
+		assert.Equal(expected, askInterruptAction(), answer)
+	}
+}
+
+func TestInterruptError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := &InterruptError{Action: interruptStop, TaskArns: []string{"aaa", "bbb"}}
+	assert.Equal("interrupted, stopped task(s): aaa, bbb", err.Error())
+	assert.Equal(ExitCodeInterrupted, ExitCode(err))
+
+	var wrapped error = &InterruptError{Action: interruptDetach, TaskArns: []string{"aaa"}}
+	assert.Equal("detached, task(s) still running: aaa", wrapped.Error())
+	assert.True(errors.As(wrapped, new(*InterruptError)))
+
+	assert.Equal("interrupted while no task(s) were running, nothing was stopped", (&InterruptError{Action: interruptAbort}).Error())
+}
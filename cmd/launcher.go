@@ -0,0 +1,465 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/hokaccha/go-prettyjson"
+)
+
+// stopCodeSpotInterruption is the stop code ECS gives tasks that were stopped
+// because their Fargate Spot capacity was reclaimed. The aws-sdk-go version we
+// pin predates it, so its TaskStopCode enum only has TaskFailedToStart,
+// EssentialContainerExited and UserInitiated.
+const stopCodeSpotInterruption = "SpotInterruption"
+
+// attempt records a single launch of our tasks and how it turned out.
+type attempt struct {
+	Capacity string   `json:"capacity"`
+	TaskArns []string `json:"taskArns"`
+	Outcome  string   `json:"outcome"`
+}
+
+// launcher launches the tasks for a RunConfig and, depending on the config,
+// follows their logs and waits for them to stop. When Fargate Spot lets us
+// down it relaunches them on on-demand Fargate, and failed tasks are relaunched
+// as the retry policy allows. Every attempt is recorded so we can summarize
+// them at the end.
+type launcher struct {
+	client        ECSClient
+	config        *RunConfig
+	spotFallbacks int
+	essential     map[string]bool
+	attempts      []*attempt
+	interrupts    *interrupter
+	deadline      time.Time
+	retries       int
+	tasks         []*ecs.Task
+	logConfig     *awsLogConfig
+	logConfigErr  error
+}
+
+func newLauncher(client ECSClient, config *RunConfig) *launcher {
+	return &launcher{
+		client:        client,
+		config:        config,
+		spotFallbacks: config.SpotFallback,
+		interrupts:    &interrupter{client: client, config: config},
+	}
+}
+
+// run launches the tasks described by the given input and returns an error if
+// they didn't all start or, when waiting, didn't all succeed.
+func (l *launcher) run(input *ecs.RunTaskInput) error {
+	var partial error
+	finished := []*ecs.Task{}
+
+	// Enforcing a timeout, retrying or falling back from Spot means watching
+	// the tasks so we wait for them to stop even without --wait.
+	wait := l.config.Wait || l.config.Timeout > 0 || l.config.Retries != nil || l.canFallback(input)
+	if l.config.Timeout > 0 {
+		l.deadline = now().Add(l.config.Timeout)
+	}
+
+	for {
+		current := &attempt{Capacity: describeCapacity(input)}
+		l.attempts = append(l.attempts, current)
+
+		output, err := l.client.RunTask(input)
+		failures, isFailures := err.(*runTaskFailuresError)
+		if err != nil && !isFailures {
+			current.Outcome = err.Error()
+			return err
+		}
+
+		current.TaskArns = aws.StringValueSlice(getTaskArns(output))
+		l.track(output.Tasks)
+		printRunTaskOutput(output)
+
+		// If ECS couldn't start any of our tasks then fall back to on-demand
+		// if Spot was the problem, otherwise there's nothing left to do.
+		if isFailures && !failures.Partial() {
+			if l.canFallback(input) && isCapacityFailure(failures) {
+				current.Outcome = "Fargate Spot capacity unavailable"
+				input = l.fallback(input, aws.Int64Value(input.Count))
+				continue
+			}
+
+			current.Outcome = "failed to start"
+			return failures
+		}
+
+		// If Spot only had capacity for some of our tasks then launch the rest
+		// on on-demand right away, so they run alongside the ones that did
+		// start and are waited on with them.
+		if isFailures && l.canFallback(input) && isCapacityFailure(failures) {
+			current.Outcome = fmt.Sprintf("started %d of %d task(s), Fargate Spot capacity unavailable for the rest", failures.Started, failures.Requested)
+			input = l.fallback(input, failures.Requested-failures.Started)
+			current = &attempt{Capacity: describeCapacity(input)}
+			l.attempts = append(l.attempts, current)
+
+			output, err = l.launchRemainder(input, output, failures)
+			current.TaskArns = aws.StringValueSlice(getTaskArns(&ecs.RunTaskOutput{Tasks: output.Tasks[failures.Started:]}))
+			failures, isFailures = err.(*runTaskFailuresError)
+			if err != nil && !isFailures {
+				current.Outcome = err.Error()
+				partial = err
+			}
+		}
+
+		// The tasks that did start may succeed, but we still didn't start as
+		// many as were asked for.
+		if isFailures {
+			partial = failures
+		}
+
+		if !wait && !l.config.Follow {
+			current.Outcome = "launched"
+			return partial
+		}
+
+		// Ctrl-C stops the tasks or detaches from them rather than just
+		// leaving them running.
+		ctx, stopWatching := l.interrupts.watch(getTaskArns(output))
+		ctx, cancel := l.withDeadline(ctx)
+		tasks, err := l.await(ctx, output, wait)
+		cancel()
+		stopWatching()
+
+		if action := l.interrupts.interrupted(); action != "" {
+			current.Outcome = "detached"
+			if action == interruptStop {
+				current.Outcome = "interrupted and stopped"
+			}
+
+			return &InterruptError{Action: action, TaskArns: current.TaskArns}
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			current.Outcome = "timed out"
+			return l.stopTimedOut(getTaskArns(output))
+		}
+
+		if err != nil {
+			current.Outcome = err.Error()
+			return err
+		}
+
+		if !wait {
+			current.Outcome = "launched"
+			return partial
+		}
+
+		l.track(tasks)
+		l.printTimelines(tasks)
+
+		// After a partial fallback the input is already on-demand, but the
+		// tasks that did start on Spot can still be interrupted.
+		interrupted, others := splitSpotInterrupted(tasks)
+		if len(interrupted) > 0 && l.spotFallbacks > 0 {
+			finished = append(finished, others...)
+			current.Outcome = fmt.Sprintf("%d task(s) interrupted by Fargate Spot", len(interrupted))
+			input = l.fallback(input, int64(len(interrupted)))
+			continue
+		}
+
+		retryable, others := l.splitRetryable(tasks)
+		if len(retryable) > 0 && l.canRetry() {
+			finished = append(finished, others...)
+			current.Outcome = describeOutcome(l.checkTasks(retryable))
+			if input, err = l.retry(input, int64(len(retryable))); err != nil {
+				l.diagnose(retryable)
+				return err
+			}
+
+			continue
+		}
+
+		current.Outcome = describeOutcome(l.checkTasks(tasks))
+
+		finished = append(finished, tasks...)
+		if err := l.checkTasks(finished); err != nil {
+			l.diagnose(finished)
+			return err
+		}
+
+		return partial
+	}
+}
+
+// await follows the logs of the tasks in the given output if we're running with
+// --logs, and then waits for them to stop if wait is given.
+func (l *launcher) await(ctx context.Context, output *ecs.RunTaskOutput, wait bool) ([]*ecs.Task, error) {
+	if l.config.Follow {
+		if err := followLogs(ctx, l.client, l.config, output); err != nil && ctx.Err() == nil {
+			log.Warn("Unable to follow task logs. ", err)
+		}
+	}
+
+	if !wait {
+		return nil, nil
+	}
+
+	return waitForTasks(ctx, l.client, output)
+}
+
+// checkTasks returns a TaskFailureError if any of the given stopped tasks failed.
+func (l *launcher) checkTasks(tasks []*ecs.Task) error {
+	if l.essential == nil {
+		taskDef, err := l.client.DescribeTaskDefinition()
+		if err != nil {
+			log.Warn("Unable to describe task definition. Using the exit code of ", l.config.ContainerName, ". ", err)
+		}
+
+		l.essential = getEssentialContainers(taskDef)
+	}
+
+	return getTasksFailure(tasks, l.essential, l.config.ContainerName)
+}
+
+// printTimelines prints how long each phase of the given stopped tasks took.
+func (l *launcher) printTimelines(tasks []*ecs.Task) {
+	if l.config.Timeline == timelineNone {
+		return
+	}
+
+	timelines := []*taskTimeline{}
+	for _, task := range tasks {
+		timelines = append(timelines, buildTimeline(task, l.getLogConfig()))
+	}
+
+	printTimelinesTable(textOut, timelines)
+}
+
+// getLogConfig looks up the awslogs settings of the container we ran the
+// command in. The log stream link is nice to have so it's nil if we can't.
+func (l *launcher) getLogConfig() *awsLogConfig {
+	if l.logConfig == nil && l.logConfigErr == nil {
+		taskDef, err := l.client.DescribeTaskDefinition()
+		if err == nil {
+			l.logConfig, err = getAwsLogConfig(taskDef, l.config.ContainerName)
+		}
+
+		l.logConfigErr = err
+	}
+
+	return l.logConfig
+}
+
+// track records the latest state of the given tasks so we can report on every
+// task we launched at the end.
+func (l *launcher) track(tasks []*ecs.Task) {
+	for _, task := range tasks {
+		found := false
+		for idx, tracked := range l.tasks {
+			if aws.StringValue(tracked.TaskArn) == aws.StringValue(task.TaskArn) {
+				l.tasks[idx] = task
+				found = true
+			}
+		}
+
+		if !found {
+			l.tasks = append(l.tasks, task)
+		}
+	}
+}
+
+// diagnose prints what we think went wrong with each of the given stopped
+// tasks that failed.
+func (l *launcher) diagnose(tasks []*ecs.Task) {
+	for _, task := range tasks {
+		if result := diagnoseTask(task, l.checkTasks([]*ecs.Task{task}), l.config); result != nil {
+			printDiagnosis(task, result)
+		}
+	}
+}
+
+// canFallback reports whether the given input runs on Fargate Spot and we
+// have on-demand fallbacks left.
+func (l *launcher) canFallback(input *ecs.RunTaskInput) bool {
+	if l.spotFallbacks <= 0 {
+		return false
+	}
+
+	for _, item := range input.CapacityProviderStrategy {
+		if aws.StringValue(item.CapacityProvider) == capacityProviderFargateSpot {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fallback uses up one of our fallbacks and returns a copy of the given input
+// that runs count tasks on on-demand Fargate instead.
+func (l *launcher) fallback(input *ecs.RunTaskInput, count int64) *ecs.RunTaskInput {
+	l.spotFallbacks--
+	log.Warn("Relaunching ", count, " task(s) on ", ecs.LaunchTypeFargate, " instead of Fargate Spot.")
+
+	onDemand := *input
+	onDemand.CapacityProviderStrategy = nil
+	onDemand.LaunchType = aws.String(ecs.LaunchTypeFargate)
+	onDemand.Count = aws.Int64(count)
+
+	return &onDemand
+}
+
+// launchRemainder launches the given on-demand input for the tasks Fargate
+// Spot didn't have capacity for and returns an output with every task that
+// started, Spot and on-demand. The error describes how many of all the tasks
+// that were asked for didn't start, if any.
+func (l *launcher) launchRemainder(input *ecs.RunTaskInput, spot *ecs.RunTaskOutput, spotFailures *runTaskFailuresError) (*ecs.RunTaskOutput, error) {
+	output, err := l.client.RunTask(input)
+	if output == nil {
+		output = &ecs.RunTaskOutput{}
+	}
+
+	l.track(output.Tasks)
+	printRunTaskOutput(output)
+
+	combined := &ecs.RunTaskOutput{
+		Tasks:    append(append([]*ecs.Task{}, spot.Tasks...), output.Tasks...),
+		Failures: output.Failures,
+	}
+
+	failures, isFailures := err.(*runTaskFailuresError)
+	if !isFailures {
+		return combined, err
+	}
+
+	return combined, &runTaskFailuresError{
+		Failures:  failures.Failures,
+		Started:   spotFailures.Started + failures.Started,
+		Requested: spotFailures.Requested,
+	}
+}
+
+// canRetry reports whether the retry policy has attempts left.
+func (l *launcher) canRetry() bool {
+	return l.config.Retries != nil && l.retries+1 < l.config.Retries.MaxAttempts
+}
+
+// splitRetryable splits the given stopped tasks into those that failed in a
+// way the retry policy allows us to retry and the rest.
+func (l *launcher) splitRetryable(tasks []*ecs.Task) ([]*ecs.Task, []*ecs.Task) {
+	retryable := []*ecs.Task{}
+	others := []*ecs.Task{}
+	for _, task := range tasks {
+		if l.config.Retries.isRetryable(task, l.checkTasks([]*ecs.Task{task})) {
+			retryable = append(retryable, task)
+		} else {
+			others = append(others, task)
+		}
+	}
+
+	return retryable, others
+}
+
+// retry uses up one of our attempts, waits out the backoff and returns a copy
+// of the given input that relaunches count tasks.
+func (l *launcher) retry(input *ecs.RunTaskInput, count int64) (*ecs.RunTaskInput, error) {
+	l.retries++
+	delay := l.config.Retries.delay(l.retries)
+	log.Warn("Retrying ", count, " failed task(s) in ", delay, ", attempt ", l.retries+1, " of ", l.config.Retries.MaxAttempts, ".")
+
+	// Nothing is running during the backoff so Ctrl-C just gives up on
+	// retrying, as does running into the timeout.
+	ctx, stopWatching := l.interrupts.watch(nil)
+	ctx, cancel := l.withDeadline(ctx)
+	err := sleep(ctx, delay)
+	cancel()
+	stopWatching()
+
+	if l.interrupts.interrupted() != "" {
+		return nil, &InterruptError{Action: interruptAbort}
+	}
+
+	if err != nil {
+		return nil, &TimeoutError{Err: fmt.Errorf("the %s timeout passed before the failed task(s) could be retried", l.config.Timeout)}
+	}
+
+	retry := *input
+	retry.Count = aws.Int64(count)
+
+	return &retry, nil
+}
+
+// printSummary prints every attempt we made if there was more than one.
+func (l *launcher) printSummary(out io.Writer) {
+	if len(l.attempts) < 2 {
+		return
+	}
+
+	cyan.Fprintf(out, "Attempts:\n")
+	for idx, current := range l.attempts {
+		arns := strings.Join(current.TaskArns, ", ")
+		if arns == "" {
+			arns = "no tasks started"
+		}
+
+		fmt.Fprintf(out, "  %d. [%s] %s: %s\n", idx+1, current.Capacity, arns, current.Outcome)
+	}
+}
+
+// isCapacityFailure reports whether any of the failures were caused by a lack
+// of Fargate capacity.
+func isCapacityFailure(failures *runTaskFailuresError) bool {
+	for _, failure := range failures.Failures {
+		if strings.Contains(strings.ToLower(aws.StringValue(failure.Reason)), "capacity") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitSpotInterrupted splits the given stopped tasks into those that were
+// interrupted by Fargate Spot and the rest.
+func splitSpotInterrupted(tasks []*ecs.Task) ([]*ecs.Task, []*ecs.Task) {
+	interrupted := []*ecs.Task{}
+	others := []*ecs.Task{}
+	for _, task := range tasks {
+		if aws.StringValue(task.StopCode) == stopCodeSpotInterruption {
+			interrupted = append(interrupted, task)
+		} else {
+			others = append(others, task)
+		}
+	}
+
+	return interrupted, others
+}
+
+// describeCapacity describes where the given input will run its tasks.
+func describeCapacity(input *ecs.RunTaskInput) string {
+	if len(input.CapacityProviderStrategy) == 0 {
+		return aws.StringValue(input.LaunchType)
+	}
+
+	providers := []string{}
+	for _, item := range input.CapacityProviderStrategy {
+		providers = append(providers, aws.StringValue(item.CapacityProvider))
+	}
+
+	return strings.Join(providers, ", ")
+}
+
+// describeOutcome describes how an attempt's tasks finished.
+func describeOutcome(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return "succeeded"
+}
+
+func printRunTaskOutput(output *ecs.RunTaskOutput) {
+	cyan.Fprintf(textOut, "RunTaskOutput: \n")
+	prettyOut, _ := prettyjson.Marshal(output)
+	fmt.Fprintln(textOut, string(prettyOut))
+}
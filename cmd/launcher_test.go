@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+func spotInput(count int64) *ecs.RunTaskInput {
+	return &ecs.RunTaskInput{
+		Count: aws.Int64(count),
+		CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String(capacityProviderFargateSpot)},
+		},
+	}
+}
+
+func startedTasks(arns ...string) *ecs.RunTaskOutput {
+	output := &ecs.RunTaskOutput{}
+	for _, arn := range arns {
+		output.Tasks = append(output.Tasks, &ecs.Task{TaskArn: aws.String(arn)})
+	}
+
+	return output
+}
+
+// Mocks
+/////////
+
+type scriptedRun struct {
+	output *ecs.RunTaskOutput
+	err    error
+	tasks  []*ecs.Task
+}
+
+// scriptedEcsClient returns the next scripted result for each RunTask call and
+// that run's stopped tasks when it's waited on.
+type scriptedEcsClient struct {
+	ecsClientFake
+	runs   []scriptedRun
+	inputs []*ecs.RunTaskInput
+}
+
+func (c *scriptedEcsClient) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	c.inputs = append(c.inputs, input)
+	run := c.runs[len(c.inputs)-1]
+
+	return run.output, run.err
+}
+
+func (c *scriptedEcsClient) WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error) {
+	return c.runs[len(c.inputs)-1].tasks, nil
+}
+
+// interruptingEcsClient sends a signal while the tasks are being waited on and
+// then waits until it's told to stop waiting.
+type interruptingEcsClient struct {
+	scriptedEcsClient
+	signals *chan<- os.Signal
+}
+
+func (c *interruptingEcsClient) WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error) {
+	*c.signals <- os.Interrupt
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+// Tests
+/////////
+
+func TestLauncherSpotCapacityFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	input := spotInput(1)
+	client := &scriptedEcsClient{runs: []scriptedRun{
+		{output: &ecs.RunTaskOutput{}, err: checkRunTaskFailures(input, &ecs.RunTaskOutput{
+			Failures: []*ecs.Failure{{Reason: aws.String("Capacity is unavailable at this time.")}},
+		})},
+		{output: startedTasks("arn:2"), tasks: []*ecs.Task{finishedTask("arn:2", "EssentialContainerExited", 0)}},
+	}}
+
+	l := newLauncher(client, &RunConfig{ContainerName: "app", SpotFallback: 1})
+	assert.Nil(l.run(input))
+
+	assert.Len(client.inputs, 2)
+	assert.Nil(client.inputs[1].CapacityProviderStrategy)
+	assert.Equal(ecs.LaunchTypeFargate, *client.inputs[1].LaunchType)
+	assert.Equal(int64(1), *client.inputs[1].Count)
+
+	assert.Len(l.attempts, 2)
+	assert.Equal(capacityProviderFargateSpot, l.attempts[0].Capacity)
+	assert.Equal("Fargate Spot capacity unavailable", l.attempts[0].Outcome)
+	assert.Equal(ecs.LaunchTypeFargate, l.attempts[1].Capacity)
+	assert.Equal([]string{"arn:2"}, l.attempts[1].TaskArns)
+	assert.Equal("succeeded", l.attempts[1].Outcome)
+
+	// The original input is left alone.
+	assert.Nil(input.LaunchType)
+}
+
+func TestLauncherSpotPartialCapacityFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	input := spotInput(3)
+	capacity := []*ecs.Failure{{Reason: aws.String("Capacity is unavailable at this time.")}}
+	spot := &ecs.RunTaskOutput{Tasks: startedTasks("arn:1").Tasks, Failures: capacity}
+	client := &scriptedEcsClient{runs: []scriptedRun{
+		{output: spot, err: checkRunTaskFailures(input, spot)},
+		{output: startedTasks("arn:2", "arn:3"), tasks: []*ecs.Task{
+			finishedTask("arn:1", "EssentialContainerExited", 0),
+			finishedTask("arn:2", "EssentialContainerExited", 0),
+			finishedTask("arn:3", "EssentialContainerExited", 0),
+		}},
+	}}
+
+	// The two tasks Spot had no capacity for are launched on on-demand right
+	// away and waited on along with the one that started.
+	l := newLauncher(client, &RunConfig{ContainerName: "app", SpotFallback: 1})
+	assert.Nil(l.run(input))
+
+	assert.Len(client.inputs, 2)
+	assert.Equal(ecs.LaunchTypeFargate, *client.inputs[1].LaunchType)
+	assert.Equal(int64(2), *client.inputs[1].Count)
+
+	assert.Len(l.attempts, 2)
+	assert.Equal([]string{"arn:1"}, l.attempts[0].TaskArns)
+	assert.Equal("started 1 of 3 task(s), Fargate Spot capacity unavailable for the rest", l.attempts[0].Outcome)
+	assert.Equal([]string{"arn:2", "arn:3"}, l.attempts[1].TaskArns)
+	assert.Equal("succeeded", l.attempts[1].Outcome)
+	assert.Len(l.tasks, 3)
+
+	// If on-demand can't start them all either then it's still a partial start
+	// of the tasks that were asked for.
+	onDemand := &ecs.RunTaskOutput{Tasks: startedTasks("arn:2").Tasks, Failures: []*ecs.Failure{{Reason: aws.String("RESOURCE:ENI")}}}
+	client = &scriptedEcsClient{runs: []scriptedRun{
+		{output: spot, err: checkRunTaskFailures(input, spot)},
+		{output: onDemand, err: checkRunTaskFailures(spotInput(2), onDemand), tasks: []*ecs.Task{
+			finishedTask("arn:1", "EssentialContainerExited", 0),
+			finishedTask("arn:2", "EssentialContainerExited", 0),
+		}},
+	}}
+
+	l = newLauncher(client, &RunConfig{ContainerName: "app", SpotFallback: 1})
+	err := l.run(input)
+
+	assert.Equal(ExitCodePartialStart, ExitCode(err))
+	assert.Contains(err.Error(), "Started 2 of 3 requested task(s).")
+}
+
+func TestLauncherSpotInterruptionFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &scriptedEcsClient{runs: []scriptedRun{
+		{output: startedTasks("arn:1", "arn:2"), tasks: []*ecs.Task{
+			finishedTask("arn:1", stopCodeSpotInterruption, 137),
+			finishedTask("arn:2", "EssentialContainerExited", 0),
+		}},
+		{output: startedTasks("arn:3"), tasks: []*ecs.Task{finishedTask("arn:3", "EssentialContainerExited", 3)}},
+	}}
+
+	// Falling back implies waiting so --wait isn't needed.
+	l := newLauncher(client, &RunConfig{ContainerName: "app", SpotFallback: 2})
+	err := l.run(spotInput(2))
+
+	// Only the interrupted task is relaunched and the interrupted exit code is ignored.
+	assert.Equal(int64(1), *client.inputs[1].Count)
+	assert.Equal(3, ExitCode(err))
+	assert.Equal("1 task(s) interrupted by Fargate Spot", l.attempts[0].Outcome)
+	assert.Equal([]string{"arn:1", "arn:2"}, l.attempts[0].TaskArns)
+	assert.Equal(1, l.spotFallbacks)
+}
+
+func TestLauncherFallbackExhausted(t *testing.T) {
+	assert := assert.New(t)
+
+	input := spotInput(1)
+	failures := checkRunTaskFailures(input, &ecs.RunTaskOutput{
+		Failures: []*ecs.Failure{{Reason: aws.String("Capacity is unavailable at this time.")}},
+	})
+	client := &scriptedEcsClient{runs: []scriptedRun{{output: &ecs.RunTaskOutput{}, err: failures}}}
+
+	l := newLauncher(client, &RunConfig{ContainerName: "app"})
+	assert.Equal(failures, l.run(input))
+	assert.Len(client.inputs, 1)
+	assert.Equal("failed to start", l.attempts[0].Outcome)
+}
+
+func TestLauncherNoWait(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &scriptedEcsClient{runs: []scriptedRun{{output: startedTasks("arn:1")}}}
+
+	// Without Spot there's nothing to fall back from so we don't wait.
+	l := newLauncher(client, &RunConfig{ContainerName: "app", SpotFallback: 1})
+	assert.Nil(l.run(&ecs.RunTaskInput{LaunchType: aws.String(ecs.LaunchTypeFargate)}))
+	assert.Equal("launched", l.attempts[0].Outcome)
+}
+
+func TestLauncherInterrupted(t *testing.T) {
+	assert := assert.New(t)
+
+	signals, _, restore := useSignals()
+	defer restore()
+
+	client := &interruptingEcsClient{
+		scriptedEcsClient: scriptedEcsClient{runs: []scriptedRun{{output: startedTasks("arn:1")}}},
+		signals:           signals,
+	}
+	l := newLauncher(client, &RunConfig{Wait: true, OnInterrupt: interruptDetach})
+
+	err := l.run(spotInput(1))
+
+	assert.Equal(&InterruptError{Action: interruptDetach, TaskArns: []string{"arn:1"}}, err)
+	assert.Equal(ExitCodeInterrupted, ExitCode(err))
+	assert.Equal("detached", l.attempts[0].Outcome)
+}
+
+func TestIsCapacityFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	capacity := &runTaskFailuresError{Failures: []*ecs.Failure{{Reason: aws.String("Capacity is unavailable at this time.")}}}
+	missing := &runTaskFailuresError{Failures: []*ecs.Failure{{Reason: aws.String("MISSING")}}}
+
+	assert.True(isCapacityFailure(capacity))
+	assert.False(isCapacityFailure(missing))
+}
+
+func TestDescribeCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("FARGATE", describeCapacity(&ecs.RunTaskInput{LaunchType: aws.String("FARGATE")}))
+	assert.Equal("FARGATE_SPOT, FARGATE", describeCapacity(&ecs.RunTaskInput{
+		CapacityProviderStrategy: getCapacityProviderStrategyItems([]CapacityProvider{
+			{Provider: capacityProviderFargateSpot},
+			{Provider: capacityProviderFargate},
+		}),
+	}))
+}
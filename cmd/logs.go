@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// logPollInterval is how long we wait between GetLogEvents calls when following logs.
+var logPollInterval = 2 * time.Second
+
+// newLogsClient is swapped out in tests to avoid calling CloudWatch.
+var newLogsClient = NewLogsClient
+
+// LogsClient is the wrapper around the aws-sdk CloudWatch Logs client.
+type LogsClient interface {
+	GetLogEvents(group, stream string, startTime *int64, nextToken *string) ([]*cloudwatchlogs.OutputLogEvent, *string, error)
+}
+
+type logsClient struct {
+	client cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+// NewLogsClient creates a new logsClient for the given RunConfig targeting the
+// given region, which is where the awslogs driver ships the task's logs to.
+func NewLogsClient(config *RunConfig, region string) LogsClient {
+	client := cloudwatchlogs.New(config.Session, aws.NewConfig().WithRegion(region))
+
+	return &logsClient{client: client}
+}
+
+// GetLogEvents fetches the next page of events from the given log stream. A nil
+// nextToken starts from the head of the stream, or from startTime (in
+// milliseconds since the epoch) if it's given. If the stream doesn't exist yet
+// then no events and the given nextToken are returned so the caller can retry.
+func (c *logsClient) GetLogEvents(group, stream string, startTime *int64, nextToken *string) ([]*cloudwatchlogs.OutputLogEvent, *string, error) {
+	output, err := c.client.GetLogEvents(&cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  &group,
+		LogStreamName: &stream,
+		NextToken:     nextToken,
+		StartFromHead: aws.Bool(true),
+		StartTime:     startTime,
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+			log.Debug("Log stream ", stream, " does not exist yet.")
+			return nil, nextToken, nil
+		}
+
+		return nil, nil, &APIError{Op: "GetLogEvents", Err: err}
+	}
+
+	return output.Events, output.NextForwardToken, nil
+}
+
+// awsLogConfig is the awslogs log driver configuration of a single container.
+type awsLogConfig struct {
+	Group         string
+	Region        string
+	StreamPrefix  string
+	ContainerName string
+}
+
+// getAwsLogConfig pulls the awslogs options for the given container out of its
+// Task Definition.
+func getAwsLogConfig(taskDef *ecs.TaskDefinition, containerName string) (*awsLogConfig, error) {
+	for _, def := range taskDef.ContainerDefinitions {
+		if aws.StringValue(def.Name) != containerName {
+			continue
+		}
+
+		logConfig := def.LogConfiguration
+		if logConfig == nil || aws.StringValue(logConfig.LogDriver) != ecs.LogDriverAwslogs {
+			return nil, fmt.Errorf("container %s does not use the awslogs log driver", containerName)
+		}
+
+		options := aws.StringValueMap(logConfig.Options)
+		if options["awslogs-group"] == "" || options["awslogs-stream-prefix"] == "" {
+			return nil, fmt.Errorf("container %s must set awslogs-group and awslogs-stream-prefix to follow its logs", containerName)
+		}
+
+		return &awsLogConfig{
+			Group:         options["awslogs-group"],
+			Region:        options["awslogs-region"],
+			StreamPrefix:  options["awslogs-stream-prefix"],
+			ContainerName: containerName,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("container %s not found in task definition", containerName)
+}
+
+// StreamName builds the name of the log stream that awslogs writes the given
+// task's logs to: prefix/container-name/task-id.
+func (l *awsLogConfig) StreamName(taskArn string) string {
+	return fmt.Sprintf("%s/%s/%s", l.StreamPrefix, l.ContainerName, getTaskID(taskArn))
+}
+
+// getRegion returns the region the logs are shipped to, which defaults to the
+// region the task runs in.
+func (l *awsLogConfig) getRegion(config *RunConfig) string {
+	if l.Region != "" {
+		return l.Region
+	}
+
+	return aws.StringValue(config.Session.Config.Region)
+}
+
+// getTaskID returns the ID portion of the given task ARN.
+func getTaskID(taskArn string) string {
+	parts := strings.Split(taskArn, "/")
+	return parts[len(parts)-1]
+}
+
+// logStream tracks how far into a single task's log stream we've printed. If
+// startTime is given then earlier events are skipped, and if grep is given only
+// the events that match it are printed. The events are printed in the given
+// output format, defaulting to text.
+type logStream struct {
+	name      string
+	prefix    string
+	taskID    string
+	container string
+	format    string
+	nextToken *string
+	startTime *int64
+	grep      *regexp.Regexp
+}
+
+// logEvent is a single log line in the json and yaml outputs.
+type logEvent struct {
+	TaskID    string    `json:"taskId"`
+	Container string    `json:"container"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// matches reports whether the given message should be printed.
+func (s *logStream) matches(message string) bool {
+	return s.grep == nil || s.grep.MatchString(message)
+}
+
+// drain prints every event currently available in the stream.
+func (s *logStream) drain(client LogsClient, group string) error {
+	for {
+		events, nextToken, err := client.GetLogEvents(group, s.name, s.startTime, s.nextToken)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if s.matches(aws.StringValue(event.Message)) {
+				s.print(event)
+			}
+		}
+
+		caughtUp := len(events) == 0 || nextToken == nil || aws.StringValue(nextToken) == aws.StringValue(s.nextToken)
+		s.nextToken = nextToken
+
+		if caughtUp {
+			return nil
+		}
+	}
+}
+
+// print prints the given event in the stream's output format. The events are
+// printed as they come in, so json is given as JSON Lines and yaml as a
+// document per event.
+func (s *logStream) print(event *cloudwatchlogs.OutputLogEvent) {
+	message := aws.StringValue(event.Message)
+	timestamp := time.Unix(0, aws.Int64Value(event.Timestamp)*int64(time.Millisecond)).UTC()
+
+	switch s.format {
+	case outputJSON:
+		encoded, _ := json.Marshal(&logEvent{s.taskID, s.container, timestamp, message})
+		fmt.Fprintln(stdout, string(encoded))
+	case outputYAML:
+		fmt.Fprintln(stdout, "---")
+		writeYAML(stdout, &logEvent{s.taskID, s.container, timestamp, message})
+	case outputTable:
+		fmt.Fprintf(stdout, "%s  %s%s\n", timestamp.Local().Format("2006-01-02 15:04:05"), s.prefix, message)
+	default:
+		fmt.Fprintln(textOut, s.prefix+message)
+	}
+}
+
+// logSource is a single container's log stream in a single task.
+type logSource struct {
+	client  LogsClient
+	group   string
+	taskArn string
+	stream  *logStream
+}
+
+// pollLogs prints the events of each of the given sources as they come in
+// until all of the given tasks have stopped or the context is done. Without
+// any tasks to wait on the sources are printed once. Tasks that are MISSING
+// are polled for up to missingGracePeriod, as in WaitForTasks.
+func pollLogs(ctx context.Context, client ECSClient, taskArns []*string, sources []*logSource) error {
+	missingUntil := time.Now().Add(missingGracePeriod)
+
+	for {
+		// Check the status before draining so we don't miss the final events.
+		stopped := true
+		if len(taskArns) > 0 {
+			tasks, err := client.DescribeTasks(taskArns)
+			if err := ignoreMissing(err, missingUntil); err != nil {
+				return err
+			}
+
+			stopped = allTasksStopped(tasks, len(taskArns))
+		}
+
+		for _, source := range sources {
+			if err := source.stream.drain(source.client, source.group); err != nil {
+				return err
+			}
+		}
+
+		if stopped {
+			return nil
+		}
+
+		if err := sleep(ctx, logPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// followLogs prints the CloudWatch logs of the tasks in the given RunTaskOutput
+// as they come in until all of those tasks have stopped or the context is done.
+func followLogs(ctx context.Context, client ECSClient, config *RunConfig, output *ecs.RunTaskOutput) error {
+	taskArns := getTaskArns(output)
+	if len(taskArns) == 0 {
+		return &NotFoundError{Err: errors.New("no tasks were started so there are no logs to follow")}
+	}
+
+	taskDef, err := client.DescribeTaskDefinition()
+	if err != nil {
+		return err
+	}
+
+	logConfig, err := getAwsLogConfig(taskDef, config.ContainerName)
+	if err != nil {
+		return err
+	}
+
+	logs := newLogsClient(config, logConfig.getRegion(config))
+
+	sources := []*logSource{}
+	for _, arn := range taskArns {
+		stream := &logStream{
+			name:      logConfig.StreamName(aws.StringValue(arn)),
+			taskID:    getTaskID(aws.StringValue(arn)),
+			container: config.ContainerName,
+		}
+
+		// Only prefix lines with the task ID if we need to tell tasks apart.
+		if len(taskArns) > 1 {
+			stream.prefix = "[" + getTaskID(aws.StringValue(arn)) + "] "
+		}
+
+		sources = append(sources, &logSource{client: logs, group: logConfig.Group, taskArn: aws.StringValue(arn), stream: stream})
+	}
+
+	log.Info("Following logs in ", logConfig.Group, " until the task stops.")
+	return pollLogs(ctx, client, taskArns, sources)
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// taskIDRegex and runIDRegex tell task IDs and ecsrun run IDs apart.
+var (
+	taskIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	runIDRegex  = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// LogsCmd prints the logs of a task that was already launched.
+var LogsCmd = &cobra.Command{
+	Use:   "logs <task-arn|task-id|run-id>",
+	Short: "Prints or follows the CloudWatch logs of a task.",
+	Long: `Prints the CloudWatch logs of every container in the given task, or every task
+of the given ecsrun run ID, prefixed with the container name. Containers have to
+use the awslogs log driver.
+
+ECS only keeps stopped tasks around for about an hour. For older tasks give the
+task ARN or ID along with --task (or a --config entry with a task) so the log
+settings can be looked up in the task definition.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := initOutput(outputText)
+		if err != nil {
+			return err
+		}
+
+		options, err := getLogsOptions()
+		if err != nil {
+			return err
+		}
+		options.Format = format
+
+		// --task is only a fallback here so it isn't bound like the root --task.
+		if cmd.Flags().Changed("task") {
+			task, _ := cmd.Flags().GetString("task")
+			viper.Set("task", task)
+		}
+
+		config, err := initTaskCommand()
+		if err != nil {
+			return err
+		}
+
+		client := newEcsClient(config)
+		tasks, err := findLogTasks(client, config, args[0])
+		if err != nil {
+			return err
+		}
+
+		sources, err := getLogSources(config, tasks, options)
+		if err != nil {
+			return err
+		}
+
+		return printLogs(client, tasks, sources, options.Follow)
+	},
+}
+
+func init() {
+	LogsCmd.Flags().BoolP("follow", "f", false, "Keep printing new logs until the task stops. (default is false)")
+	LogsCmd.Flags().String("since", "", "Only print logs newer than a duration, e.g. 30m, or a timestamp, e.g. 2020-06-01T12:00:00Z.")
+	LogsCmd.Flags().StringSlice("container", []string{}, "Only print the logs of the given container(s). Can be repeated or comma separated.")
+	LogsCmd.Flags().String("grep", "", "Only print the log lines that match the given regular expression.")
+	LogsCmd.Flags().StringP("task", "t", "", "The Task Definition to look up log settings in if ECS no longer knows about the task, e.g. my-task:3.")
+
+	for _, name := range []string{"follow", "since", "container", "grep"} {
+		viper.BindPFlag(name, LogsCmd.Flags().Lookup(name))
+	}
+}
+
+// logsOptions are the flags of the logs command.
+type logsOptions struct {
+	Follow     bool
+	StartTime  *int64
+	Containers []string
+	Grep       *regexp.Regexp
+	Format     string
+}
+
+func getLogsOptions() (*logsOptions, error) {
+	options := &logsOptions{
+		Follow:     viper.GetBool("follow"),
+		Containers: viper.GetStringSlice("container"),
+	}
+
+	if since := viper.GetString("since"); since != "" {
+		startTime, err := parseSince(since, now())
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+
+		options.StartTime = aws.Int64(aws.TimeUnixMilli(startTime))
+	}
+
+	if grep := viper.GetString("grep"); grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, &ConfigError{Err: fmt.Errorf("invalid grep: %s", err)}
+		}
+
+		options.Grep = re
+	}
+
+	return options, nil
+}
+
+// parseSince parses --since as either a duration before now or a timestamp.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(since); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid since %q, expected a duration like 30m or a timestamp like 2020-06-01T12:00:00Z", since)
+}
+
+// findLogTasks looks up the tasks for the given task ARN, task ID, or run ID.
+// If ECS has forgotten about a stopped task then we fall back to the task
+// definition from the config so we can still find its logs.
+func findLogTasks(client ECSClient, config *RunConfig, id string) ([]*ecs.Task, error) {
+	// A run ID is only in the tasks' tags so we have to look through the
+	// cluster's tasks for it, but a task can be described directly.
+	if runIDRegex.MatchString(id) {
+		tasks, err := findTasks(client, taskFilter{RunID: id})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tasks) == 0 {
+			return nil, &NotFoundError{Err: fmt.Errorf("no tasks found for run %s, ECS only keeps stopped tasks for about an hour so give the task ID instead", id)}
+		}
+
+		return tasks, nil
+	}
+
+	described, err := client.DescribeTasks([]*string{aws.String(id)})
+	if err == nil && len(described) > 0 {
+		return described, nil
+	}
+
+	// Only a task ECS doesn't know about is not found, anything else is a
+	// problem talking to the API.
+	if err != nil && !isMissingTasks(err) {
+		return nil, err
+	}
+	log.Debug("Unable to describe task ", id, ". ", err)
+
+	if !strings.HasPrefix(id, "arn:") && !taskIDRegex.MatchString(id) {
+		return nil, &NotFoundError{Err: fmt.Errorf("task %s not found", id)}
+	}
+
+	if config.TaskDefinition == "" {
+		return nil, &ConfigError{Err: fmt.Errorf("task %s not found, ECS only keeps stopped tasks for about an hour so give --task to look up its logs", id)}
+	}
+
+	log.Info("Task ", id, " not found, using the log settings of task definition ", config.TaskDefinition, ".")
+	return []*ecs.Task{{
+		TaskArn:           aws.String(id),
+		TaskDefinitionArn: aws.String(config.TaskDefinition),
+		LastStatus:        aws.String(ecs.DesiredStatusStopped),
+	}}, nil
+}
+
+// getLogSources looks up the awslogs settings of every container in each of the
+// given tasks' task definitions and returns the log streams to print.
+func getLogSources(config *RunConfig, tasks []*ecs.Task, options *logsOptions) ([]*logSource, error) {
+	sources := []*logSource{}
+	taskDefs := make(map[string]*ecs.TaskDefinition)
+	found := make(map[string]bool)
+
+	for _, task := range tasks {
+		taskDefArn := aws.StringValue(task.TaskDefinitionArn)
+		taskDef, ok := taskDefs[taskDefArn]
+		if !ok {
+			taskConfig := *config
+			taskConfig.TaskDefinition = taskDefArn
+
+			var err error
+			taskDef, err = newEcsClient(&taskConfig).DescribeTaskDefinition()
+			if err != nil {
+				return nil, err
+			}
+
+			taskDefs[taskDefArn] = taskDef
+		}
+
+		for _, def := range taskDef.ContainerDefinitions {
+			name := aws.StringValue(def.Name)
+			if len(options.Containers) > 0 && !containsString(options.Containers, name) {
+				continue
+			}
+
+			found[name] = true
+			logConfig, err := getAwsLogConfig(taskDef, name)
+			if err != nil {
+				log.Warn("Skipping the logs of container ", name, ". ", err)
+				continue
+			}
+
+			prefix := name
+			if len(tasks) > 1 {
+				prefix = getTaskID(aws.StringValue(task.TaskArn)) + "/" + name
+			}
+
+			sources = append(sources, &logSource{
+				client:  newLogsClient(config, logConfig.getRegion(config)),
+				group:   logConfig.Group,
+				taskArn: aws.StringValue(task.TaskArn),
+				stream: &logStream{
+					name:      logConfig.StreamName(aws.StringValue(task.TaskArn)),
+					prefix:    "[" + prefix + "] ",
+					taskID:    getTaskID(aws.StringValue(task.TaskArn)),
+					container: name,
+					format:    options.Format,
+					startTime: options.StartTime,
+					grep:      options.Grep,
+				},
+			})
+		}
+	}
+
+	for _, name := range options.Containers {
+		if !found[name] {
+			return nil, &ConfigError{Err: fmt.Errorf("container %s not found in the task definition", name)}
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, &ConfigError{Err: errors.New("none of the task's containers use the awslogs log driver")}
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].stream.prefix < sources[j].stream.prefix })
+	return sources, nil
+}
+
+// printLogs prints every log source and, if follow is given, keeps printing
+// new events until all of the tasks have stopped.
+func printLogs(client ECSClient, tasks []*ecs.Task, sources []*logSource, follow bool) error {
+	running := []*string{}
+	if follow {
+		for _, task := range tasks {
+			if aws.StringValue(task.LastStatus) != ecs.DesiredStatusStopped {
+				running = append(running, task.TaskArn)
+			}
+		}
+	}
+
+	return pollLogs(context.Background(), client, running, sources)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
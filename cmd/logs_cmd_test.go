@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+const logsTaskArn = "arn:aws:ecs:us-east-1:123456789012:task/cluster/0123456789abcdef0123456789abcdef"
+
+func sidecarTaskDefinition() *ecs.TaskDefinition {
+	taskDef := awslogsTaskDefinition(map[string]string{
+		"awslogs-group":         "app-logs",
+		"awslogs-stream-prefix": "ecs",
+	})
+	taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions,
+		&ecs.ContainerDefinition{
+			Name: aws.String("proxy"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options: aws.StringMap(map[string]string{
+					"awslogs-group":         "proxy-logs",
+					"awslogs-region":        "us-west-2",
+					"awslogs-stream-prefix": "ecs",
+				}),
+			},
+		},
+		&ecs.ContainerDefinition{Name: aws.String("xray")},
+	)
+
+	return taskDef
+}
+
+func useTaskDefinition(taskDef *ecs.TaskDefinition) func() {
+	previousNewEcsClient := newEcsClient
+	newEcsClient = func(config *RunConfig) ECSClient {
+		return newClient(&ecsAPIFake{taskDefinition: taskDef}, config)
+	}
+
+	return func() { newEcsClient = previousNewEcsClient }
+}
+
+func useLogsClient(logs *logsClientFake) func() {
+	previousNewLogsClient := newLogsClient
+	newLogsClient = func(config *RunConfig, region string) LogsClient {
+		logs.region = region
+		return logs
+	}
+
+	return func() { newLogsClient = previousNewLogsClient }
+}
+
+func testLogsConfig() *RunConfig {
+	return &RunConfig{
+		Cluster: "cluster",
+		Session: session.Must(session.NewSession(aws.NewConfig().WithRegion("us-east-1"))),
+	}
+}
+
+// Mocks
+/////////
+
+// describeFailsEcsClient acts like ECS has forgotten about every task, or
+// fails with err if it's set.
+type describeFailsEcsClient struct {
+	tasksEcsClient
+	err error
+}
+
+func (c *describeFailsEcsClient) DescribeTasks(taskArns []*string) ([]*ecs.Task, error) {
+	if len(taskArns) == 0 {
+		return []*ecs.Task{}, nil
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return nil, &APIError{Op: "DescribeTasks", Err: &missingTasksError{TaskArns: aws.StringValueSlice(taskArns)}}
+}
+
+// Tests
+/////////
+
+func TestParseSince(t *testing.T) {
+	assert := assert.New(t)
+
+	since, err := parseSince("90m", testStartedAt)
+	assert.Nil(err)
+	assert.Equal(testStartedAt.Add(-90*time.Minute), since)
+
+	since, err = parseSince("2020-06-01T10:00:00Z", testStartedAt)
+	assert.Nil(err)
+	assert.Equal(testStartedAt.Add(-2*time.Hour), since.UTC())
+
+	_, err = parseSince("yesterday", testStartedAt)
+	assert.EqualError(err, `invalid since "yesterday", expected a duration like 30m or a timestamp like 2020-06-01T12:00:00Z`)
+}
+
+func TestFindLogTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	launched := launchedTask(logsTaskArn, "0123456789abcdef", "migrate", "", testStartedAt)
+	other := launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/other", "fedcba9876543210", "migrate", "", testStartedAt)
+	client := &tasksEcsClient{running: []*ecs.Task{launched, other}}
+
+	for _, id := range []string{logsTaskArn, "0123456789abcdef0123456789abcdef", "0123456789abcdef"} {
+		tasks, err := findLogTasks(client, &RunConfig{}, id)
+		assert.Nil(err, id)
+		assert.Equal([]*ecs.Task{launched}, tasks, id)
+	}
+
+	// Tasks are described directly, only run IDs look through every task.
+	assert.Equal([]int{1, 1, 2}, client.describeCalls)
+
+	// ECS has forgotten about the task so we need the task definition.
+	forgetful := &describeFailsEcsClient{}
+	_, err := findLogTasks(forgetful, &RunConfig{}, "ffffffffffffffffffffffffffffffff")
+	assert.Equal(ExitCodeConfig, ExitCode(err))
+
+	tasks, err := findLogTasks(forgetful, &RunConfig{TaskDefinition: "migrate:3"}, "ffffffffffffffffffffffffffffffff")
+	assert.Nil(err)
+	assert.Equal("migrate:3", *tasks[0].TaskDefinitionArn)
+	assert.Equal("STOPPED", *tasks[0].LastStatus)
+
+	_, err = findLogTasks(forgetful, &RunConfig{TaskDefinition: "migrate:3"}, "ffffffffffffffff")
+	assert.Contains(err.Error(), "no tasks found for run ffffffffffffffff")
+	assert.Equal(ExitCodeNotFound, ExitCode(err))
+
+	_, err = findLogTasks(forgetful, &RunConfig{TaskDefinition: "migrate:3"}, "not-a-task")
+	assert.EqualError(err, "task not-a-task not found")
+	assert.Equal(ExitCodeNotFound, ExitCode(err))
+
+	// Any other failure to describe the task isn't mistaken for it not existing.
+	denied := &describeFailsEcsClient{err: &APIError{Op: "DescribeTasks", Err: errors.New("ACCESS_DENIED")}}
+	_, err = findLogTasks(denied, &RunConfig{TaskDefinition: "migrate:3"}, "ffffffffffffffffffffffffffffffff")
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+}
+
+func TestGetLogSources(t *testing.T) {
+	assert := assert.New(t)
+	defer useTaskDefinition(sidecarTaskDefinition())()
+	defer useLogsClient(&logsClientFake{})()
+
+	tasks := []*ecs.Task{{TaskArn: aws.String(logsTaskArn), TaskDefinitionArn: aws.String("migrate:3")}}
+	options := &logsOptions{StartTime: aws.Int64(1000)}
+
+	// Containers without awslogs are skipped.
+	sources, err := getLogSources(testLogsConfig(), tasks, options)
+	assert.Nil(err)
+	assert.Len(sources, 2)
+	assert.Equal("app-logs", sources[0].group)
+	assert.Equal("ecs/app/0123456789abcdef0123456789abcdef", sources[0].stream.name)
+	assert.Equal("[app] ", sources[0].stream.prefix)
+	assert.Equal(int64(1000), *sources[0].stream.startTime)
+	assert.Equal("proxy-logs", sources[1].group)
+
+	// Multiple tasks are told apart by their ID.
+	tasks = append(tasks, &ecs.Task{TaskArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task/cluster/other"), TaskDefinitionArn: aws.String("migrate:3")})
+	sources, _ = getLogSources(testLogsConfig(), tasks, &logsOptions{Containers: []string{"proxy"}})
+	assert.Len(sources, 2)
+	assert.Equal("[0123456789abcdef0123456789abcdef/proxy] ", sources[0].stream.prefix)
+	assert.Equal("[other/proxy] ", sources[1].stream.prefix)
+
+	_, err = getLogSources(testLogsConfig(), tasks, &logsOptions{Containers: []string{"nope"}})
+	assert.EqualError(err, "container nope not found in the task definition")
+
+	_, err = getLogSources(testLogsConfig(), tasks, &logsOptions{Containers: []string{"xray"}})
+	assert.EqualError(err, "none of the task's containers use the awslogs log driver")
+	assert.IsType(&ConfigError{}, err)
+}
+
+func TestPrintLogs(t *testing.T) {
+	assert := assert.New(t)
+
+	previousInterval := logPollInterval
+	logPollInterval = time.Millisecond
+	defer func() { logPollInterval = previousInterval }()
+
+	logs := &logsClientFake{pages: map[string][][]string{"ecs/app/abc": {{"hello"}, {"world"}}}}
+	sources := []*logSource{{client: logs, group: "app-logs", stream: &logStream{name: "ecs/app/abc"}}}
+
+	api := &ecsAPIFake{describeTasks: []*ecs.DescribeTasksOutput{
+		{Tasks: []*ecs.Task{describedTask(logsTaskArn, "RUNNING")}},
+		{Tasks: []*ecs.Task{describedTask(logsTaskArn, "STOPPED")}},
+	}}
+	client := newClient(api, &RunConfig{Cluster: "cluster"})
+	tasks := []*ecs.Task{describedTask(logsTaskArn, "RUNNING")}
+
+	// Without follow the logs are printed once.
+	assert.Nil(printLogs(client, tasks, sources, false))
+	assert.Equal(0, api.describeTasksCalls)
+
+	assert.Nil(printLogs(client, tasks, sources, true))
+	assert.Equal(2, api.describeTasksCalls)
+}
+
+func TestLogStreamGrep(t *testing.T) {
+	assert := assert.New(t)
+
+	logs := &logsClientFake{pages: map[string][][]string{"ecs/app/abc": {{"ERROR one", "INFO two"}}}}
+	stream := &logStream{name: "ecs/app/abc", grep: regexp.MustCompile("^ERROR")}
+
+	assert.True(stream.matches("ERROR one"))
+	assert.False(stream.matches("INFO two"))
+	assert.True((&logStream{}).matches("INFO two"))
+
+	assert.Nil(stream.drain(logs, "app-logs"))
+	assert.Equal("x", aws.StringValue(stream.nextToken))
+}
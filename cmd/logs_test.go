@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks
+/////////
+
+// logsClientFake serves up pages of events keyed by stream name. A stream with
+// no pages acts like one that doesn't exist yet.
+type logsClientFake struct {
+	pages   map[string][][]string
+	streams []string
+	region  string
+}
+
+func (c *logsClientFake) GetLogEvents(group, stream string, startTime *int64, nextToken *string) ([]*cloudwatchlogs.OutputLogEvent, *string, error) {
+	c.streams = append(c.streams, stream)
+
+	page := 0
+	if nextToken != nil {
+		page = len(*nextToken)
+	}
+
+	if page >= len(c.pages[stream]) {
+		return nil, nextToken, nil
+	}
+
+	events := []*cloudwatchlogs.OutputLogEvent{}
+	for _, message := range c.pages[stream][page] {
+		events = append(events, &cloudwatchlogs.OutputLogEvent{Message: aws.String(message)})
+	}
+
+	token := ""
+	for i := 0; i <= page; i++ {
+		token += "x"
+	}
+
+	return events, &token, nil
+}
+
+func awslogsTaskDefinition(options map[string]string) *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("app"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options:   aws.StringMap(options),
+				},
+			},
+		},
+	}
+}
+
+// Tests
+/////////
+
+func TestGetAwsLogConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	taskDef := awslogsTaskDefinition(map[string]string{
+		"awslogs-group":         "app-logs",
+		"awslogs-region":        "us-west-2",
+		"awslogs-stream-prefix": "ecs",
+	})
+
+	actual, err := getAwsLogConfig(taskDef, "app")
+	assert.Nil(err)
+	assert.Equal(&awsLogConfig{
+		Group:         "app-logs",
+		Region:        "us-west-2",
+		StreamPrefix:  "ecs",
+		ContainerName: "app",
+	}, actual)
+	assert.Equal("ecs/app/abc123", actual.StreamName("arn:aws:ecs:us-west-2:123456789012:task/cluster/abc123"))
+
+	_, err = getAwsLogConfig(taskDef, "missing")
+	assert.EqualError(err, "container missing not found in task definition")
+
+	noPrefix := awslogsTaskDefinition(map[string]string{"awslogs-group": "app-logs"})
+	_, err = getAwsLogConfig(noPrefix, "app")
+	assert.NotNil(err)
+
+	splunk := awslogsTaskDefinition(map[string]string{})
+	splunk.ContainerDefinitions[0].LogConfiguration.LogDriver = aws.String("splunk")
+	_, err = getAwsLogConfig(splunk, "app")
+	assert.EqualError(err, "container app does not use the awslogs log driver")
+}
+
+func TestGetTaskID(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("abc123", getTaskID("arn:aws:ecs:us-east-1:123456789012:task/cluster/abc123"))
+	assert.Equal("abc123", getTaskID("arn:aws:ecs:us-east-1:123456789012:task/abc123"))
+	assert.Equal("abc123", getTaskID("abc123"))
+}
+
+func TestLogStreamDrain(t *testing.T) {
+	assert := assert.New(t)
+
+	logs := &logsClientFake{
+		pages: map[string][][]string{
+			"ecs/app/abc123": {{"one", "two"}, {"three"}},
+		},
+	}
+
+	stream := &logStream{name: "ecs/app/abc123"}
+	err := stream.drain(logs, "app-logs")
+
+	assert.Nil(err)
+	assert.Equal("xx", aws.StringValue(stream.nextToken))
+
+	// A stream that doesn't exist yet is not an error.
+	missing := &logStream{name: "ecs/app/missing"}
+	err = missing.drain(logs, "app-logs")
+
+	assert.Nil(err)
+	assert.Nil(missing.nextToken)
+}
+
+func TestFollowLogs(t *testing.T) {
+	assert := assert.New(t)
+
+	previousInterval := logPollInterval
+	logPollInterval = time.Millisecond
+	defer func() { logPollInterval = previousInterval }()
+
+	logs := &logsClientFake{
+		pages: map[string][][]string{
+			"ecs/app/abc123": {{"hello"}, {"world"}},
+		},
+	}
+	previousNewLogsClient := newLogsClient
+	newLogsClient = func(config *RunConfig, region string) LogsClient {
+		logs.region = region
+		return logs
+	}
+	defer func() { newLogsClient = previousNewLogsClient }()
+
+	arn := "arn:aws:ecs:us-east-1:123456789012:task/cluster/abc123"
+	api := &ecsAPIFake{
+		taskDefinition: awslogsTaskDefinition(map[string]string{
+			"awslogs-group":         "app-logs",
+			"awslogs-stream-prefix": "ecs",
+		}),
+		describeTasks: []*ecs.DescribeTasksOutput{
+			{Tasks: []*ecs.Task{describedTask(arn, "PENDING")}},
+			{Tasks: []*ecs.Task{describedTask(arn, "STOPPED")}},
+		},
+	}
+	config := &RunConfig{
+		Cluster:       "cluster",
+		ContainerName: "app",
+		Session:       session.Must(session.NewSession(aws.NewConfig().WithRegion("us-east-1"))),
+	}
+	client := newClient(api, config)
+	output := &ecs.RunTaskOutput{Tasks: []*ecs.Task{{TaskArn: aws.String(arn)}}}
+
+	err := followLogs(context.Background(), client, config, output)
+
+	assert.Nil(err)
+	assert.Equal(2, api.describeTasksCalls)
+	assert.Equal("us-east-1", logs.region)
+	assert.Contains(logs.streams, "ecs/app/abc123")
+
+	err = followLogs(context.Background(), client, config, &ecs.RunTaskOutput{})
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestPollLogsMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	previousInterval := logPollInterval
+	logPollInterval = time.Millisecond
+	defer func() { logPollInterval = previousInterval }()
+
+	arn := "arn:aws:ecs:us-east-1:123456789012:task/cluster/abc123"
+	missing := &ecs.DescribeTasksOutput{Failures: []*ecs.Failure{{Arn: aws.String(arn), Reason: aws.String("MISSING")}}}
+	api := &ecsAPIFake{
+		describeTasks: []*ecs.DescribeTasksOutput{
+			missing,
+			{Tasks: []*ecs.Task{describedTask(arn, "STOPPED")}},
+		},
+	}
+	client := newClient(api, &RunConfig{Cluster: "cluster"})
+
+	// A task that was just launched can be MISSING for a bit.
+	assert.Nil(pollLogs(context.Background(), client, []*string{aws.String(arn)}, nil))
+	assert.Equal(2, api.describeTasksCalls)
+
+	// But not forever.
+	previousGracePeriod := missingGracePeriod
+	missingGracePeriod = 0
+	defer func() { missingGracePeriod = previousGracePeriod }()
+
+	api = &ecsAPIFake{describeTasks: []*ecs.DescribeTasksOutput{missing}}
+	err := pollLogs(context.Background(), newClient(api, &RunConfig{Cluster: "cluster"}), []*string{aws.String(arn)}, nil)
+	assert.True(isMissingTasks(err))
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+}
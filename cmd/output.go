@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// The formats of the global --output flag. text is the human readable output
+// with progress along the way, the rest print only the command's result so it
+// can be scripted against.
+const (
+	outputText  = "text"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// stdout is where a command's result is printed and textOut is where the
+// human readable progress along the way is printed. With any output other
+// than text the progress goes to stderr so stdout only holds the result.
+var (
+	stdout  io.Writer = os.Stdout
+	textOut io.Writer = os.Stdout
+)
+
+// initOutput reads the --output format, using the given default for the
+// command if it wasn't given, and points the progress output at the right
+// place for it.
+func initOutput(defaultFormat string) (string, error) {
+	format := viper.GetString("output")
+	if format == "" {
+		format = defaultFormat
+	}
+
+	switch format {
+	case outputText:
+		textOut = stdout
+	case outputTable, outputJSON, outputYAML:
+		textOut = os.Stderr
+	default:
+		return "", &ConfigError{Err: fmt.Errorf("invalid output %q, expected json, yaml, table, or text", format)}
+	}
+
+	return format, nil
+}
+
+// render prints the given result in the given format. JSON and YAML share the
+// JSON field names, while the table and text formats are up to the command.
+func render(format string, result interface{}, table, text func(out io.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case outputYAML:
+		return writeYAML(stdout, result)
+	case outputTable:
+		table(stdout)
+	default:
+		text(stdout)
+	}
+
+	return nil
+}
+
+// writeYAML writes the given result as YAML using its JSON field names, so
+// the two formats can be scripted against the same way.
+func writeYAML(out io.Writer, result interface{}) error {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	var decoded interface{}
+	if err := yaml.Unmarshal(encoded, &decoded); err != nil {
+		return err
+	}
+
+	bytes, err := yaml.Marshal(decoded)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(bytes)
+	return err
+}
+
+// sdkJSON encodes one of the aws-sdk's shapes the way the ECS API does, with
+// camelCase keys and without the fields that aren't set.
+func sdkJSON(shape interface{}) json.RawMessage {
+	encoded, err := json.Marshal(shape)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return json.RawMessage("null")
+	}
+
+	encoded, err = json.Marshal(apiKeys(decoded))
+	if err != nil {
+		return json.RawMessage("null")
+	}
+
+	return encoded
+}
+
+// apiKeys turns the field names of a decoded aws-sdk shape into the API's by
+// lowercasing their first letter, e.g. TaskDefinition to taskDefinition, and
+// drops the fields that are null since they weren't set.
+func apiKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			if field == nil || key == "" {
+				continue
+			}
+
+			result[strings.ToLower(key[:1])+key[1:]] = apiKeys(field)
+		}
+
+		return result
+	case []interface{}:
+		for idx, item := range value {
+			value[idx] = apiKeys(item)
+		}
+
+		return value
+	default:
+		return value
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+// useStdout captures everything printed to stdout and textOut in the given
+// buffer.
+func useStdout(out *bytes.Buffer) func() {
+	previousStdout, previousTextOut := stdout, textOut
+	stdout, textOut = out, out
+
+	return func() { stdout, textOut = previousStdout, previousTextOut }
+}
+
+type outputResult struct {
+	RunID    string   `json:"runId"`
+	TaskArns []string `json:"taskArns"`
+}
+
+// Tests
+/////////
+
+func TestInitOutput(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+	defer useStdout(&bytes.Buffer{})()
+
+	format, err := initOutput(outputTable)
+	assert.Nil(err)
+	assert.Equal(outputTable, format)
+	assert.Equal(os.Stderr, textOut)
+
+	viper.Set("output", outputText)
+	format, err = initOutput(outputTable)
+	assert.Nil(err)
+	assert.Equal(outputText, format)
+	assert.Equal(stdout, textOut)
+
+	// Anything but text keeps stdout for the result.
+	for _, format := range []string{outputJSON, outputYAML} {
+		viper.Set("output", format)
+		_, err = initOutput(outputText)
+		assert.Nil(err)
+		assert.Equal(os.Stderr, textOut, format)
+	}
+
+	viper.Set("output", "xml")
+	_, err = initOutput(outputText)
+	assert.IsType(&ConfigError{}, err)
+	assert.EqualError(err, `invalid output "xml", expected json, yaml, table, or text`)
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &bytes.Buffer{}
+	defer useStdout(out)()
+
+	result := &outputResult{RunID: "0123456789abcdef", TaskArns: []string{"arn:1", "arn:2"}}
+	table := func(out io.Writer) { io.WriteString(out, "table\n") }
+	text := func(out io.Writer) { io.WriteString(out, "text\n") }
+
+	assert.Nil(render(outputJSON, result, table, text))
+	decoded := &outputResult{}
+	assert.Nil(json.Unmarshal(out.Bytes(), decoded))
+	assert.Equal(result, decoded)
+
+	// YAML uses the same field names as JSON.
+	out.Reset()
+	assert.Nil(render(outputYAML, result, table, text))
+	assert.Equal("runId: 0123456789abcdef\ntaskArns:\n- arn:1\n- arn:2\n", out.String())
+
+	out.Reset()
+	assert.Nil(render(outputTable, result, table, text))
+	assert.Equal("table\n", out.String())
+
+	out.Reset()
+	assert.Nil(render(outputText, result, table, text))
+	assert.Equal("text\n", out.String())
+}
+
+func TestSdkJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	input := &ecs.RunTaskInput{Cluster: aws.String("cluster"), Count: aws.Int64(2)}
+	assert.JSONEq(`{"cluster": "cluster", "count": 2}`, string(sdkJSON(input)))
+
+	input.Overrides = &ecs.TaskOverride{
+		ContainerOverrides: []*ecs.ContainerOverride{{Name: aws.String("app"), Command: aws.StringSlice([]string{"echo", "hi"})}},
+		EphemeralStorage:   &ecs.EphemeralStorage{SizeInGiB: aws.Int64(50)},
+	}
+	assert.JSONEq(`{
+		"cluster": "cluster",
+		"count": 2,
+		"overrides": {
+			"containerOverrides": [{"name": "app", "command": ["echo", "hi"]}],
+			"ephemeralStorage": {"sizeInGiB": 50}
+		}
+	}`, string(sdkJSON(input)))
+}
+
+func TestLogStreamPrint(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &bytes.Buffer{}
+	defer useStdout(out)()
+
+	event := &cloudwatchlogs.OutputLogEvent{Message: aws.String("hello"), Timestamp: aws.Int64(1590984000000)}
+	stream := &logStream{prefix: "[app] ", taskID: "abc123", container: "app"}
+
+	stream.print(event)
+	assert.Equal("[app] hello\n", out.String())
+
+	// JSON is printed as a line per event so it can be streamed into jq.
+	out.Reset()
+	stream.format = outputJSON
+	stream.print(event)
+	stream.print(event)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(lines, 2)
+	assert.JSONEq(`{"taskId": "abc123", "container": "app", "timestamp": "2020-06-01T04:00:00Z", "message": "hello"}`, lines[0])
+
+	out.Reset()
+	stream.format = outputYAML
+	stream.print(event)
+	assert.Equal("---\ncontainer: app\nmessage: hello\ntaskId: abc123\ntimestamp: \"2020-06-01T04:00:00Z\"\n", out.String())
+}
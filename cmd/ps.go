@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxCommandWidth is how much of a task's command we show in the ps table.
+const maxCommandWidth = 40
+
+// PsCmd lists the tasks launched by ecsrun.
+var PsCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "Lists the running and recently stopped tasks launched by ecsrun.",
+	Long: `Lists the running and recently stopped tasks in the cluster that were launched
+by ecsrun. By default only your own tasks are listed, use --all-users to list
+everyone's. Give --config to only list the tasks launched from that config entry.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := initOutput(outputTable)
+		if err != nil {
+			return err
+		}
+
+		config, err := initTaskCommand()
+		if err != nil {
+			return err
+		}
+
+		filter, err := getTaskFilter(config, viper.GetBool("all-users"))
+		if err != nil {
+			return err
+		}
+
+		tasks, err := findTasks(newEcsClient(config), filter)
+		if err != nil {
+			return err
+		}
+
+		summaries := []*taskSummary{}
+		for _, task := range tasks {
+			summaries = append(summaries, summarizeTask(task))
+		}
+
+		return render(format, summaries, func(out io.Writer) {
+			printTaskSummariesTable(out, summaries)
+		}, func(out io.Writer) {
+			printTaskArns(out, summaries)
+		})
+	},
+}
+
+func init() {
+	PsCmd.Flags().Bool("all-users", false, "List the tasks launched by every user, not just you. (default is false)")
+
+	viper.BindPFlag("all-users", PsCmd.Flags().Lookup("all-users"))
+}
+
+// getTaskFilter builds the filter for the tasks to work with from the --config
+// flag and, unless allUsers is given, the caller's identity.
+func getTaskFilter(config *RunConfig, allUsers bool) (taskFilter, error) {
+	filter := taskFilter{}
+
+	// --config always has a value so only filter on it if it was given.
+	if viper.IsSet("config") {
+		filter.ConfigEntry = viper.GetString("config")
+	}
+
+	if !allUsers {
+		identity, err := getCallerIdentity(config)
+		if err != nil {
+			return filter, err
+		}
+
+		filter.Caller = aws.StringValue(identity.Arn)
+	}
+
+	return filter, nil
+}
+
+// taskSummary is a single row of ecsrun ps.
+type taskSummary struct {
+	TaskArn     string     `json:"taskArn"`
+	TaskID      string     `json:"taskId"`
+	RunID       string     `json:"runId"`
+	ConfigEntry string     `json:"config"`
+	User        string     `json:"user"`
+	Command     []string   `json:"command"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"startedAt"`
+	StoppedAt   *time.Time `json:"stoppedAt"`
+	Duration    string     `json:"duration"`
+	ExitCode    *int64     `json:"exitCode"`
+}
+
+func summarizeTask(task *ecs.Task) *taskSummary {
+	tags := getTaskTags(task)
+	summary := &taskSummary{
+		TaskArn:     aws.StringValue(task.TaskArn),
+		TaskID:      getTaskID(aws.StringValue(task.TaskArn)),
+		RunID:       tags[tagRunID],
+		ConfigEntry: tags[tagConfigEntry],
+		User:        callerName(tags[tagCaller]),
+		Command:     []string{},
+		Status:      aws.StringValue(task.LastStatus),
+		StartedAt:   task.StartedAt,
+		StoppedAt:   task.StoppedAt,
+	}
+
+	if task.StartedAt != nil {
+		summary.Duration = getTaskDuration(task).String()
+	}
+
+	if override := getMainContainerOverride(task); override != nil {
+		summary.Command = aws.StringValueSlice(override.Command)
+	}
+
+	if container := getMainContainer(task); container != nil {
+		summary.ExitCode = container.ExitCode
+	}
+
+	return summary
+}
+
+// printTaskArns prints just the ARN of each task, one per line, so they can be
+// piped into other commands.
+func printTaskArns(out io.Writer, summaries []*taskSummary) {
+	for _, summary := range summaries {
+		fmt.Fprintln(out, summary.TaskArn)
+	}
+}
+
+func printTaskSummariesTable(out io.Writer, summaries []*taskSummary) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK ID\tCONFIG\tUSER\tCOMMAND\tSTATUS\tSTARTED\tDURATION\tEXIT CODE")
+
+	for _, summary := range summaries {
+		command := strings.Join(summary.Command, " ")
+		if len(command) > maxCommandWidth {
+			command = command[:maxCommandWidth-3] + "..."
+		}
+
+		started := "-"
+		if summary.StartedAt != nil {
+			started = summary.StartedAt.Local().Format("2006-01-02 15:04:05")
+		}
+
+		exitCode := "-"
+		if summary.ExitCode != nil {
+			exitCode = fmt.Sprintf("%d", *summary.ExitCode)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			summary.TaskID,
+			valueOrDash(summary.ConfigEntry),
+			valueOrDash(summary.User),
+			valueOrDash(command),
+			summary.Status,
+			started,
+			valueOrDash(summary.Duration),
+			exitCode)
+	}
+
+	w.Flush()
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestSummarizeTask(t *testing.T) {
+	assert := assert.New(t)
+
+	migration := tagged(stoppedTask(container("app", aws.Int64(0))), "run-1", "migrate", "arn:aws:iam::123456789012:user/matt")
+	ranCommand(migration, "python", "./manage.py", "migrate", "--database", "default", "--noinput")
+
+	summary := summarizeTask(migration)
+	assert.Equal("abc123", summary.TaskID)
+	assert.Equal("run-1", summary.RunID)
+	assert.Equal("migrate", summary.ConfigEntry)
+	assert.Equal("matt", summary.User)
+	assert.Equal("STOPPED", summary.Status)
+	assert.Equal("10m31s", summary.Duration)
+	assert.Equal(int64(0), *summary.ExitCode)
+
+	// A task that hasn't started yet.
+	summary = summarizeTask(launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/def456", "run-2", "", "", testStartedAt))
+	assert.Equal("", summary.Duration)
+	assert.Equal([]string{}, summary.Command)
+	assert.Nil(summary.ExitCode)
+}
+
+func TestPrintTaskSummariesTable(t *testing.T) {
+	assert := assert.New(t)
+
+	migration := tagged(stoppedTask(container("app", aws.Int64(0))), "run-1", "migrate", "arn:aws:iam::123456789012:user/matt")
+	ranCommand(migration, "python", "./manage.py", "migrate", "--database", "default", "--noinput")
+
+	out := &bytes.Buffer{}
+	printTaskSummariesTable(out, []*taskSummary{summarizeTask(migration)})
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	assert.Len(lines, 2)
+	assert.Contains(string(lines[0]), "TASK ID  CONFIG   USER  COMMAND")
+	assert.Contains(string(lines[1]), "abc123   migrate  matt  python ./manage.py migrate --database...  STOPPED")
+	assert.Contains(string(lines[1]), "10m31s    0")
+}
+
+func TestRenderTaskSummariesJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &bytes.Buffer{}
+	defer useStdout(out)()
+
+	assert.Nil(render(outputJSON, []*taskSummary{summarizeTask(ranCommand(stoppedTask(container("app", aws.Int64(0))), "migrate"))}, nil, nil))
+
+	assert.Contains(out.String(), `"taskId": "abc123"`)
+	assert.Contains(out.String(), `"startedAt": "2020-06-01T12:01:40Z"`)
+	assert.Contains(out.String(), `"exitCode": 0`)
+
+	out.Reset()
+	assert.Nil(render(outputJSON, []*taskSummary{}, nil, nil))
+	assert.Equal("[]\n", out.String())
+}
+
+func TestPrintTaskArns(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &bytes.Buffer{}
+	printTaskArns(out, []*taskSummary{summarizeTask(stoppedTask())})
+
+	assert.Equal("arn:aws:ecs:us-east-1:123456789012:task/cluster/abc123\n", out.String())
+}
+
+func TestGetTaskFilter(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+	defer useStsAPIFake(&stsAPIFake{})()
+
+	filter, err := getTaskFilter(&RunConfig{}, false)
+	assert.Nil(err)
+	assert.Equal(taskFilter{Caller: "arn:aws:iam::123456789012:user/matt"}, filter)
+
+	viper.Set("config", "migrate")
+	filter, err = getTaskFilter(&RunConfig{}, true)
+	assert.Nil(err)
+	assert.Equal(taskFilter{ConfigEntry: "migrate"}, filter)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// fargateMemory lists the memory values in MiB that Fargate supports for each
+// task CPU value in CPU units (1024 units = 1 vCPU).
+var fargateMemory = map[int64][]int64{
+	256:   {512, 1024, 2048},
+	512:   memoryRange(1024, 4096, 1024),
+	1024:  memoryRange(2048, 8192, 1024),
+	2048:  memoryRange(4096, 16384, 1024),
+	4096:  memoryRange(8192, 30720, 1024),
+	8192:  memoryRange(16384, 61440, 4096),
+	16384: memoryRange(32768, 122880, 8192),
+}
+
+// The ephemeral storage in GiB that ECS allows Fargate tasks to override.
+const (
+	minEphemeralStorage = 21
+	maxEphemeralStorage = 200
+)
+
+func memoryRange(from, to, step int64) []int64 {
+	result := []int64{}
+	for value := from; value <= to; value += step {
+		result = append(result, value)
+	}
+
+	return result
+}
+
+// validateResources checks the CPU and memory overrides in the given config
+// before we call RunTask. Fargate only supports specific pairings of task CPU
+// and memory so if only one of them is overridden we look up the other in
+// the Task Definition.
+func validateResources(client ECSClient, config *RunConfig) error {
+	if err := validateContainerResources(config); err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	fargate := config.LaunchType == ecs.LaunchTypeFargate || usesOnlyFargate(config.CapacityProviderStrategy)
+	if err := validateEphemeralStorage(config.EphemeralStorage, fargate); err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	if !fargate || (config.CPU == 0 && config.Memory == 0) {
+		return nil
+	}
+
+	cpu, memory := config.CPU, config.Memory
+	if cpu == 0 || memory == 0 {
+		taskDef, err := client.DescribeTaskDefinition()
+		if err != nil {
+			return err
+		}
+
+		if cpu == 0 {
+			cpu, _ = strconv.ParseInt(aws.StringValue(taskDef.Cpu), 10, 64)
+		}
+		if memory == 0 {
+			memory, _ = strconv.ParseInt(aws.StringValue(taskDef.Memory), 10, 64)
+		}
+	}
+
+	if err := validateFargateResources(cpu, memory); err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	return nil
+}
+
+// validateEphemeralStorage checks the ephemeral storage override in GiB is one
+// ECS accepts. Only Fargate tasks support it and the default is 20 GiB.
+func validateEphemeralStorage(size int64, fargate bool) error {
+	if size == 0 {
+		return nil
+	}
+
+	if !fargate {
+		return errors.New("ephemeral-storage is only supported for Fargate tasks")
+	}
+
+	if size < minEphemeralStorage || size > maxEphemeralStorage {
+		return fmt.Errorf("ephemeral-storage must be between %d and %d GiB", minEphemeralStorage, maxEphemeralStorage)
+	}
+
+	return nil
+}
+
+// validateFargateResources checks that the given task CPU and memory are a
+// pairing that Fargate supports.
+func validateFargateResources(cpu, memory int64) error {
+	memories, ok := fargateMemory[cpu]
+	if !ok {
+		cpus := []int64{}
+		for value := range fargateMemory {
+			cpus = append(cpus, value)
+		}
+		sort.Slice(cpus, func(i, j int) bool { return cpus[i] < cpus[j] })
+
+		return fmt.Errorf("Fargate doesn't support a task cpu of %d, expected one of %v", cpu, cpus)
+	}
+
+	for _, value := range memories {
+		if value == memory {
+			return nil
+		}
+	}
+
+	// Only describe the memories as a range if they're evenly spaced, e.g. not
+	// 512, 1024 and 2048 for a cpu of 256.
+	step := memories[1] - memories[0]
+	for idx := 2; idx < len(memories); idx++ {
+		if memories[idx]-memories[idx-1] != step {
+			return fmt.Errorf("Fargate doesn't support %d MiB of memory with a task cpu of %d, expected one of %v", memory, cpu, memories)
+		}
+	}
+
+	return fmt.Errorf("Fargate doesn't support %d MiB of memory with a task cpu of %d, expected %d to %d MiB in steps of %d",
+		memory, cpu, memories[0], memories[len(memories)-1], step)
+}
+
+// validateContainerResources checks that the container overrides fit together
+// and inside the task overrides.
+func validateContainerResources(config *RunConfig) error {
+	for key, value := range map[string]int64{
+		"cpu":                config.CPU,
+		"memory":             config.Memory,
+		"container-cpu":      config.ContainerCPU,
+		"container-memory":   config.ContainerMemory,
+		"memory-reservation": config.MemoryReservation,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s can't be negative", key)
+		}
+	}
+
+	if config.ContainerMemory > 0 && config.MemoryReservation > config.ContainerMemory {
+		return errors.New("memory-reservation can't be more than container-memory")
+	}
+
+	if config.CPU > 0 && config.ContainerCPU > config.CPU {
+		return errors.New("container-cpu can't be more than the task cpu")
+	}
+
+	if config.Memory > 0 && config.ContainerMemory > config.Memory {
+		return errors.New("container-memory can't be more than the task memory")
+	}
+
+	for name, container := range config.Containers {
+		if config.CPU > 0 && aws.Int64Value(container.CPU) > config.CPU {
+			return fmt.Errorf("containers.%s.cpu can't be more than the task cpu", name)
+		}
+
+		if config.Memory > 0 && aws.Int64Value(container.Memory) > config.Memory {
+			return fmt.Errorf("containers.%s.memory can't be more than the task memory", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestValidateFargateResources(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateFargateResources(256, 512))
+	assert.Nil(validateFargateResources(4096, 16384))
+	assert.Nil(validateFargateResources(16384, 122880))
+
+	err := validateFargateResources(4096, 4096)
+	assert.EqualError(err, "Fargate doesn't support 4096 MiB of memory with a task cpu of 4096, expected 8192 to 30720 MiB in steps of 1024")
+
+	err = validateFargateResources(256, 1536)
+	assert.EqualError(err, "Fargate doesn't support 1536 MiB of memory with a task cpu of 256, expected one of [512 1024 2048]")
+
+	err = validateFargateResources(3000, 8192)
+	assert.EqualError(err, "Fargate doesn't support a task cpu of 3000, expected one of [256 512 1024 2048 4096 8192 16384]")
+}
+
+func TestValidateEphemeralStorage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateEphemeralStorage(0, false))
+	assert.Nil(validateEphemeralStorage(21, true))
+	assert.Nil(validateEphemeralStorage(200, true))
+	assert.EqualError(validateEphemeralStorage(20, true), "ephemeral-storage must be between 21 and 200 GiB")
+	assert.EqualError(validateEphemeralStorage(201, true), "ephemeral-storage must be between 21 and 200 GiB")
+	assert.EqualError(validateEphemeralStorage(100, false), "ephemeral-storage is only supported for Fargate tasks")
+}
+
+func TestValidateContainerResources(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateContainerResources(&RunConfig{ContainerCPU: 1024, ContainerMemory: 2048, MemoryReservation: 1024}))
+	assert.EqualError(validateContainerResources(&RunConfig{CPU: -1}), "cpu can't be negative")
+	assert.EqualError(validateContainerResources(&RunConfig{ContainerMemory: 512, MemoryReservation: 1024}), "memory-reservation can't be more than container-memory")
+	assert.EqualError(validateContainerResources(&RunConfig{CPU: 1024, ContainerCPU: 2048}), "container-cpu can't be more than the task cpu")
+	assert.EqualError(validateContainerResources(&RunConfig{Memory: 2048, ContainerMemory: 4096}), "container-memory can't be more than the task memory")
+	assert.EqualError(validateContainerResources(&RunConfig{
+		CPU:        1024,
+		Containers: map[string]*ContainerConfig{"sidecar": {CPU: aws.Int64(2048)}},
+	}), "containers.sidecar.cpu can't be more than the task cpu")
+}
+
+func TestValidateResources(t *testing.T) {
+	assert := assert.New(t)
+
+	taskDef := &ecs.TaskDefinition{Cpu: aws.String("256"), Memory: aws.String("512")}
+	client := newClient(&ecsAPIFake{taskDefinition: taskDef}, &RunConfig{})
+
+	// Nothing to check without overrides.
+	assert.Nil(validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeFargate}))
+
+	// Both given.
+	assert.Nil(validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeFargate, CPU: 4096, Memory: 16384}))
+
+	// Only the cpu given so the task definition's memory is too small.
+	err := validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeFargate, CPU: 4096})
+	assert.Equal(ExitCodeConfig, ExitCode(err))
+	assert.Contains(err.Error(), "512 MiB of memory with a task cpu of 4096")
+
+	// Only the memory given, using the task definition's cpu.
+	assert.Nil(validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeFargate, Memory: 2048}))
+
+	// Fargate capacity providers are checked too.
+	err = validateResources(client, &RunConfig{
+		CapacityProviderStrategy: []CapacityProvider{{Provider: capacityProviderFargateSpot}},
+		CPU:                      1024,
+		Memory:                   512,
+	})
+	assert.NotNil(err)
+
+	// EC2 has no fixed pairings.
+	assert.Nil(validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeEc2, CPU: 3000, Memory: 100}))
+
+	// But it doesn't support ephemeral storage.
+	err = validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeEc2, EphemeralStorage: 50})
+	assert.Equal(ExitCodeConfig, ExitCode(err))
+	assert.Nil(validateResources(client, &RunConfig{LaunchType: ecs.LaunchTypeFargate, EphemeralStorage: 50}))
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+)
+
+// The defaults of a retry policy that leaves them out.
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = 10 * time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+)
+
+// RetryPolicy is the schema of the retries key of a config entry. Failed tasks
+// are relaunched until they succeed or MaxAttempts is reached, waiting an
+// exponentially growing backoff between attempts. If neither ExitCodes nor
+// StopCodes are given then every failure is retried.
+type RetryPolicy struct {
+	MaxAttempts int      `yaml:"max-attempts"`
+	ExitCodes   []int    `yaml:"exit-codes"`
+	StopCodes   []string `yaml:"stop-codes"`
+	Backoff     *string  `yaml:"backoff"`
+	MaxBackoff  *string  `yaml:"max-backoff"`
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// initRetries validates the retry policy from the config file and fills in
+// its defaults so it's ready to be used by the launcher.
+func initRetries() error {
+	policy, ok := viper.Get("retries").(RetryPolicy)
+	if !ok {
+		return nil
+	}
+
+	if err := initRetryPolicy(&policy); err != nil {
+		return &ConfigError{Err: fmt.Errorf("retries: %s", err)}
+	}
+
+	viper.Set("retries", &policy)
+	return nil
+}
+
+func initRetryPolicy(policy *RetryPolicy) error {
+	if policy.MaxAttempts < 0 {
+		return errors.New("max-attempts can't be negative")
+	}
+
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+
+	var err error
+	if policy.backoff, err = parseBackoff("backoff", policy.Backoff, defaultBackoff); err != nil {
+		return err
+	}
+
+	if policy.maxBackoff, err = parseBackoff("max-backoff", policy.MaxBackoff, defaultMaxBackoff); err != nil {
+		return err
+	}
+
+	if policy.maxBackoff < policy.backoff {
+		return errors.New("max-backoff can't be less than backoff")
+	}
+
+	return nil
+}
+
+// parseBackoff parses the given backoff duration, or returns the default if
+// it isn't given.
+func parseBackoff(key string, value *string, defaultValue time.Duration) (time.Duration, error) {
+	if value == nil {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(*value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a duration like 10s or 5m", key, *value)
+	}
+
+	return duration, nil
+}
+
+// getRetryPolicy reads the policy stored by initRetries, which is nil if the
+// config entry doesn't have one.
+func getRetryPolicy() *RetryPolicy {
+	policy, _ := viper.Get("retries").(*RetryPolicy)
+	return policy
+}
+
+// isRetryable reports whether the given stopped task failed in a way the
+// policy allows us to retry. failure is the task's failure from getTaskFailure.
+func (p *RetryPolicy) isRetryable(task *ecs.Task, failure error) bool {
+	if p == nil || failure == nil {
+		return false
+	}
+
+	// Someone stopping the task on purpose isn't a failure worth retrying
+	// unless the policy asks for it.
+	if len(p.ExitCodes) == 0 && len(p.StopCodes) == 0 {
+		return aws.StringValue(task.StopCode) != ecs.TaskStopCodeUserInitiated
+	}
+
+	if containsString(p.StopCodes, aws.StringValue(task.StopCode)) {
+		return true
+	}
+
+	var taskFailure *TaskFailureError
+	if errors.As(failure, &taskFailure) {
+		for _, code := range p.ExitCodes {
+			if code == taskFailure.Code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// delay returns how long to wait before the given retry, starting from 1. The
+// backoff doubles with each retry up to the max backoff.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < retry && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+
+	return delay
+}
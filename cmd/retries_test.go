@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestInitRetries(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	// No policy is fine.
+	assert.Nil(initRetries())
+	assert.Nil(getRetryPolicy())
+
+	entry, err := parseConfigEntry([]byte(`sync:
+  retries:
+    exit-codes: [1, 75]
+    stop-codes: [TaskFailedToStart]
+    backoff: 30s
+`), "sync")
+	assert.Nil(err)
+	assert.Nil(viper.MergeConfigMap(entry.ToMap()))
+
+	assert.Nil(initRetries())
+	policy := getRetryPolicy()
+	assert.Equal(defaultMaxAttempts, policy.MaxAttempts)
+	assert.Equal([]int{1, 75}, policy.ExitCodes)
+	assert.Equal([]string{"TaskFailedToStart"}, policy.StopCodes)
+	assert.Equal(30*time.Second, policy.backoff)
+	assert.Equal(defaultMaxBackoff, policy.maxBackoff)
+
+	for _, invalid := range []RetryPolicy{
+		{MaxAttempts: -1},
+		{Backoff: aws.String("soon")},
+		{Backoff: aws.String("1m"), MaxBackoff: aws.String("10s")},
+	} {
+		viper.Set("retries", invalid)
+		assert.IsType(&ConfigError{}, initRetries(), invalid)
+	}
+}
+
+func TestRetryPolicyIsRetryable(t *testing.T) {
+	assert := assert.New(t)
+
+	failure := &TaskFailureError{Code: 75}
+	task := finishedTask("arn:1", "EssentialContainerExited", 75)
+
+	var none *RetryPolicy
+	assert.False(none.isRetryable(task, failure))
+
+	// Without exit or stop codes every failure is retried, unless the task
+	// was stopped by someone.
+	assert.True((&RetryPolicy{}).isRetryable(task, failure))
+	assert.False((&RetryPolicy{}).isRetryable(task, nil))
+
+	userStopped := finishedTask("arn:3", ecs.TaskStopCodeUserInitiated, 137)
+	assert.False((&RetryPolicy{}).isRetryable(userStopped, &TaskFailureError{Code: 137}))
+	assert.True((&RetryPolicy{StopCodes: []string{ecs.TaskStopCodeUserInitiated}}).isRetryable(userStopped, &TaskFailureError{Code: 137}))
+
+	policy := &RetryPolicy{ExitCodes: []int{75}, StopCodes: []string{"TaskFailedToStart"}}
+	assert.True(policy.isRetryable(task, failure))
+	assert.False(policy.isRetryable(task, &TaskFailureError{Code: 1}))
+
+	notStarted := finishedTask("arn:2", "TaskFailedToStart", 0)
+	assert.True(policy.isRetryable(notStarted, &TaskFailureError{Code: ExitCodeNotStarted}))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := &RetryPolicy{backoff: 10 * time.Second, maxBackoff: time.Minute}
+	assert.Equal(10*time.Second, policy.delay(1))
+	assert.Equal(20*time.Second, policy.delay(2))
+	assert.Equal(40*time.Second, policy.delay(3))
+	assert.Equal(time.Minute, policy.delay(4))
+	assert.Equal(time.Minute, policy.delay(10))
+}
+
+func TestLauncherRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := &RetryPolicy{MaxAttempts: 3, ExitCodes: []int{75}}
+	assert.Nil(initRetryPolicy(policy))
+	policy.backoff = time.Millisecond
+	policy.maxBackoff = time.Millisecond
+
+	input := &ecs.RunTaskInput{Count: aws.Int64(2), LaunchType: aws.String(ecs.LaunchTypeFargate)}
+	client := &scriptedEcsClient{runs: []scriptedRun{
+		{output: startedTasks("arn:1", "arn:2"), tasks: []*ecs.Task{
+			finishedTask("arn:1", "EssentialContainerExited", 75),
+			finishedTask("arn:2", "EssentialContainerExited", 0),
+		}},
+		{output: startedTasks("arn:3"), tasks: []*ecs.Task{finishedTask("arn:3", "EssentialContainerExited", 75)}},
+		{output: startedTasks("arn:4"), tasks: []*ecs.Task{finishedTask("arn:4", "EssentialContainerExited", 0)}},
+	}}
+
+	// Retrying implies waiting on the tasks.
+	l := newLauncher(client, &RunConfig{ContainerName: "app", Retries: policy})
+	err := l.run(input)
+
+	assert.Nil(err)
+	assert.Len(client.inputs, 3)
+	assert.Equal(input, client.inputs[0])
+	assert.Equal(int64(1), aws.Int64Value(client.inputs[1].Count))
+	assert.Equal(ecs.LaunchTypeFargate, aws.StringValue(client.inputs[2].LaunchType))
+	assert.Equal("task arn:1 failed: container app exited with code 75", l.attempts[0].Outcome)
+	assert.Equal("succeeded", l.attempts[2].Outcome)
+
+	// Once the attempts run out the last failure is returned.
+	client = &scriptedEcsClient{runs: []scriptedRun{
+		{output: startedTasks("arn:1"), tasks: []*ecs.Task{finishedTask("arn:1", "EssentialContainerExited", 75)}},
+		{output: startedTasks("arn:2"), tasks: []*ecs.Task{finishedTask("arn:2", "EssentialContainerExited", 75)}},
+		{output: startedTasks("arn:3"), tasks: []*ecs.Task{finishedTask("arn:3", "EssentialContainerExited", 75)}},
+	}}
+	l = newLauncher(client, &RunConfig{ContainerName: "app", Retries: policy})
+	err = l.run(&ecs.RunTaskInput{Count: aws.Int64(1)})
+
+	assert.Len(client.inputs, 3)
+	assert.Equal(75, ExitCode(err))
+
+	// Failures the policy doesn't cover aren't retried.
+	client = &scriptedEcsClient{runs: []scriptedRun{
+		{output: startedTasks("arn:1"), tasks: []*ecs.Task{finishedTask("arn:1", "EssentialContainerExited", 1)}},
+	}}
+	l = newLauncher(client, &RunConfig{ContainerName: "app", Retries: policy})
+	err = l.run(&ecs.RunTaskInput{Count: aws.Int64(1)})
+
+	assert.Len(client.inputs, 1)
+	assert.Equal(1, ExitCode(err))
+}
+
+func TestLauncherRetryInterrupted(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, restore := useSignals()
+	defer restore()
+
+	// Ctrl-C is pressed as soon as the backoff starts.
+	notifySignals = func(signals chan<- os.Signal) { signals <- os.Interrupt }
+
+	policy := &RetryPolicy{MaxAttempts: 2}
+	assert.Nil(initRetryPolicy(policy))
+	policy.backoff = time.Hour
+
+	l := newLauncher(&scriptedEcsClient{}, &RunConfig{ContainerName: "app", Retries: policy})
+	_, err := l.retry(&ecs.RunTaskInput{Count: aws.Int64(1)}, 1)
+
+	// Nothing was running so nothing was stopped.
+	assert.Equal(&InterruptError{Action: interruptAbort}, err)
+	assert.Equal(ExitCodeInterrupted, ExitCode(err))
+	assert.Equal("interrupted while no task(s) were running, nothing was stopped", err.Error())
+}
@@ -3,30 +3,33 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/fatih/color"
 	"github.com/hokaccha/go-prettyjson"
-	"gopkg.in/yaml.v2"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 // VersionInfo is used by the `--version` command to output version info.
 type VersionInfo struct {
-	Version string
-	Commit  string
-	Date    string
-	BuiltBy string
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"builtBy"`
 }
 
 func (v VersionInfo) String() string {
@@ -38,36 +41,136 @@ func (v VersionInfo) String() string {
 		v.BuiltBy)
 }
 
+// printTable prints the version info as a single row.
+func (v VersionInfo) printTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCOMMIT\tDATE BUILT\tBUILT BY")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Version, v.Commit, v.Date, v.BuiltBy)
+	w.Flush()
+}
+
 var (
 	vInfo        VersionInfo
 	log          = logrus.New()
 	fs           = afero.NewOsFs()
 	newEcsClient func(*RunConfig) ECSClient
 	cyan         = color.New(color.FgCyan, color.Bold)
+	red          = color.New(color.FgRed)
 )
 
 var rootCmd *cobra.Command = &cobra.Command{
-	Use:   "escrun",
+	Use:   "ecsrun [flags] [-- command...]",
 	Short: "Easily run one-off tasks against an ECS Cluster",
 	Long: `ecsrun is a CLI tool that allows users to run one-off administrative tasks
-using their existing Task Definitions.`,
+using their existing Task Definitions.
+
+The command to run can be given after -- exactly as you'd type it, e.g.:
+
+  ecsrun --config migrate -- python manage.py migrate --fake`,
+
+	// Only allow positional args after -- where they're the command to run.
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+			return &ConfigError{Err: fmt.Errorf("unknown command %q for %q, pass the command to run after --", args[0], cmd.CommandPath())}
+		}
+
+		return nil
+	},
+
+	SilenceErrors: true,
+	SilenceUsage:  true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := initOutput(outputText)
+		if err != nil {
+			return err
+		}
+
+		if viper.GetBool("version") {
+			return render(format, vInfo, vInfo.printTable, func(out io.Writer) {
+				fmt.Fprint(out, vInfo.String())
+			})
+		}
 
-	Run: func(cmd *cobra.Command, args []string) {
 		initEnvVars()
-		initAws()
-		if err := initConfigFile(); err != nil {
-			log.Debug(err)
+		if err := initAws(); err != nil {
+			return err
+		}
+
+		if err := initConfigFile(false); err != nil {
+			return err
+		}
+
+		if err := initCommand(cmd.Flags(), args); err != nil {
+			return err
+		}
+
+		if err := initEnvironment(cmd.Flags()); err != nil {
+			return err
 		}
 
-		// Raise and exit if we're missing any required flags
-		if err := checkRequired(); err != nil {
-			fmt.Print(err.Error())
-			os.Exit(1)
+		if err := initCapacityProviderStrategy(); err != nil {
+			return err
+		}
+
+		if err := initContainers(); err != nil {
+			return err
+		}
+
+		if err := initTimeout(); err != nil {
+			return err
+		}
+
+		if err := initRetries(); err != nil {
+			return err
+		}
+
+		// Raise if we're missing any required flags. The containers map can
+		// give the commands instead of cmd.
+		required := []string{"cluster", "task"}
+		if len(getContainers()) == 0 {
+			required = append(required, "cmd")
+		}
+
+		if err := checkRequired(required...); err != nil {
+			return err
 		}
 
 		config := BuildRunConfig()
 		ecsClient := newEcsClient(config)
 
+		// Only awsvpc tasks need a subnet and security group.
+		if err := initNetworkMode(ecsClient, config); err != nil {
+			return err
+		}
+
+		if config.NetworkMode == ecs.NetworkModeAwsvpc {
+			if err := checkRequired("subnet", "security-group"); err != nil {
+				return err
+			}
+		}
+
+		if err := validateResources(ecsClient, config); err != nil {
+			return err
+		}
+
+		if err := validateTags(config.Tags, config.PropagateTags); err != nil {
+			return &ConfigError{Err: err}
+		}
+
+		if err := validateOnInterrupt(config.OnInterrupt); err != nil {
+			return err
+		}
+
+		if err := validateTimeline(config.Timeline); err != nil {
+			return err
+		}
+
+		initRunInfo(config, viper.GetString("config-entry"), !viper.GetBool("dry-run"))
+		if err := initRoles(config); err != nil {
+			return err
+		}
+
 		input := ecsClient.BuildRunTaskInput()
 
 		// Oooh fancy.
@@ -76,72 +179,128 @@ using their existing Task Definitions.`,
 
 		// If we're running with --dry-run then print the input and exit.
 		if viper.GetBool("dry-run") {
-			cyan.Printf("DryRun! RunTaskInput:\n")
-			fmt.Println(prettyString)
-
-			os.Exit(0)
+			result := newDryRunResult(config, input)
+			return render(format, result, result.printTable, func(out io.Writer) {
+				cyan.Fprintf(out, "DryRun! RunTaskInput:\n")
+				fmt.Fprintln(out, prettyString)
+
+				if len(config.CapacityProviderStrategy) > 0 {
+					cyan.Fprintf(out, "Capacity provider strategy: ")
+					fmt.Fprintln(out, formatCapacityProviderStrategy(config.CapacityProviderStrategy))
+				}
+			})
 		}
 
 		log.Debug("RunTaskInput: ", prettyString)
-		output, err := ecsClient.RunTask(input)
-		if err != nil {
-			log.Fatal(err)
+		launcher := newLauncher(ecsClient, config)
+		err = launcher.run(input)
+
+		result := launcher.result(err)
+		if renderErr := render(format, result, result.printTable, launcher.printSummary); renderErr != nil {
+			log.Warn("Unable to print the run result. ", renderErr)
 		}
 
-		cyan.Printf("RunTaskOutput: \n")
-		prettyOut, _ := prettyjson.Marshal(output)
-		fmt.Println(string(prettyOut))
+		return err
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Any error is printed to stderr and mapped to ecsrun's exit code table.
 func Execute(n func(*RunConfig) ECSClient, v VersionInfo) {
 	newEcsClient = n
 	vInfo = v
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+		red.Fprintln(os.Stderr, strings.TrimSpace(err.Error()))
+		os.Exit(ExitCode(err))
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initVerbose, initVersion)
+	cobra.OnInitialize(initVerbose)
 
 	log.SetOutput(os.Stderr)
 
 	// Basic Flags
-	rootCmd.Flags().BoolP("verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "output format: json, yaml, table, or text. Progress goes to stderr for anything but text. (default depends on the command)")
 	rootCmd.Flags().Bool("version", false, "version output")
 
 	// Config File Flags
-	rootCmd.Flags().String("config-file", "", "config file to read config entries from (default is $PWD/escrun.yml)")
-	rootCmd.Flags().String("config", "default", "config entry to read in the config file (default is 'default')")
+	rootCmd.PersistentFlags().String("config-file", "", "config file to read config entries from (default is $PWD/escrun.yml)")
+	rootCmd.PersistentFlags().String("config", "default", "config entry to read in the config file (default is 'default')")
 	rootCmd.Flags().Bool("dry-run", false, "dry-run your ecsrun execution to check config (default is false)")
+	rootCmd.Flags().Bool("wait", false, "wait for the task to stop and exit with its container's exit code (default is false)")
+	rootCmd.Flags().Bool("logs", false, "follow the task's CloudWatch logs until it stops, also available as --follow (default is false)")
+	rootCmd.Flags().Duration("timeout", 0, "stop the task and exit with code 124 if it hasn't stopped within this long, e.g. 30m. Implies --wait. (default is 0, no timeout)")
+	rootCmd.Flags().String("timeline", timelineTable, "whether to print how long each phase of the task took after waiting on it: table or none")
+	rootCmd.Flags().String("on-interrupt", interruptAsk, "what to do with the task on Ctrl-C while following or waiting on it: ask, stop, or detach")
 
 	// AWS Cred / Environment Flags
-	rootCmd.Flags().String("cred", "", "AWS credentials file (default is $HOME/.aws/.credentials)")
-	rootCmd.Flags().StringP("profile", "p", "", "AWS profile to target (default is AWS_PROFILE or 'default')")
-	rootCmd.Flags().String("region", "", `AWS region to target (default is AWS_REGION or pulled from $HOME/.aws/.credentials)`)
+	rootCmd.PersistentFlags().String("cred", "", "AWS credentials file (default is $HOME/.aws/.credentials)")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "AWS profile to target (default is AWS_PROFILE or 'default')")
+	rootCmd.PersistentFlags().String("region", "", `AWS region to target (default is AWS_REGION or pulled from $HOME/.aws/.credentials)`)
 
 	// Task Flags
-	rootCmd.Flags().StringP("cluster", "c", "", "The ECS Cluster to run the task in.")
+	rootCmd.PersistentFlags().StringP("cluster", "c", "", "The ECS Cluster to run the task in.")
 	rootCmd.Flags().StringP("task", "t", "", "The name of the ECS Task Definition to use.")
 	rootCmd.Flags().StringP("revision", "r", "", "The Task Definition revision to use.")
 	rootCmd.Flags().StringP("name", "n", "", "The name of the container in the Task Definition.")
-	rootCmd.Flags().StringP("launch-type", "l", "FARGATE", "The launch type to run as. Currently only Fargate is supported.")
+	rootCmd.Flags().StringP("launch-type", "l", "FARGATE", "The launch type to run as: FARGATE or EC2.")
+	rootCmd.Flags().StringSlice("capacity-provider-strategy", []string{}, "The capacity provider strategy to use instead of a launch type, as provider[:weight[:base]]. Can be repeated or comma separated.")
+	rootCmd.Flags().Int("spot-fallback", 0, "Relaunch on on-demand FARGATE up to this many times if Fargate Spot capacity is unavailable or the task is interrupted. Implies --wait. (default is 0, disabled)")
+	rootCmd.Flags().String("task-role", "", "The IAM role for the task's containers to use, as a role name in your account or an ARN.")
+	rootCmd.Flags().String("execution-role", "", "The IAM role for ECS to pull images and fetch secrets with, as a role name in your account or an ARN.")
+	rootCmd.Flags().String("network-mode", "", "The network mode of the Task Definition. Looked up from the Task Definition for non-Fargate launch types if not given.")
 	rootCmd.Flags().StringSlice("cmd", []string{}, "The comma separated command override to apply.")
+	rootCmd.Flags().String("shell-cmd", "", "The command override to apply as a single string, split using shell quoting rules.")
 	rootCmd.Flags().Int64("count", 1, "The number of tasks to launch for the given cmd.")
+	rootCmd.Flags().Int64("cpu", 0, "The task CPU override in CPU units, e.g. 4096 for 4 vCPU. Checked against the Fargate CPU / memory pairings.")
+	rootCmd.Flags().Int64("memory", 0, "The task memory override in MiB, e.g. 16384 for 16 GB.")
+	rootCmd.Flags().Int64("container-cpu", 0, "The CPU units to reserve for the container.")
+	rootCmd.Flags().Int64("container-memory", 0, "The hard memory limit of the container in MiB.")
+	rootCmd.Flags().Int64("memory-reservation", 0, "The soft memory limit of the container in MiB.")
+	rootCmd.Flags().Int64("ephemeral-storage", 0, "The ephemeral storage for a Fargate task in GiB, from 21 to 200. (default is 20 GiB)")
 
 	// Network Flags
-	rootCmd.Flags().StringP("subnet", "s", "", "The Subnet ID that the task should be launched in.")
-	rootCmd.Flags().StringP("security-group", "g", "", "The Security Group ID that the task should be associated with.")
+	rootCmd.Flags().StringSliceP("subnet", "s", []string{}, "The Subnet ID(s) that the task can be launched in. Can be repeated or comma separated.")
+	rootCmd.Flags().StringSliceP("security-group", "g", []string{}, "The Security Group ID(s) that the task should be associated with. Can be repeated or comma separated.")
 	rootCmd.Flags().Bool("public", false, "Assigns a public IP to the task if included. (default is false)")
+	rootCmd.Flags().Bool("no-tags", false, "Don't tag the task with the ecsrun:* run info, for credentials without ecs:TagResource. (default is false)")
+	rootCmd.Flags().String("propagate-tags", "", "Copy the tags from the Task Definition to the task: TASK_DEFINITION or NONE. (default is NONE)")
+
+	// --follow is an alias of --logs.
+	rootCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "follow" {
+			name = "logs"
+		}
+
+		return pflag.NormalizedName(name)
+	})
+
+	// Treat bad flags the same as any other bad config.
+	rootCmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return &ConfigError{Err: err}
+	})
 
-	// Bind all cobra flags to Viper. viper.Get is used heavily.
+	// Bind all cobra flags to Viper. viper.Get is used heavily. The persistent
+	// flags are shared with the sub commands.
+	viper.BindPFlags(rootCmd.PersistentFlags())
 	viper.BindPFlags(rootCmd.Flags())
 
+	// Environment Flags
+	// These are repeatable and merged with the config file rather than
+	// replacing it, so they're read from the FlagSet directly instead of
+	// being bound to Viper.
+	rootCmd.Flags().StringArrayP("env", "e", []string{}, "An environment variable to set in the container as KEY=VALUE. Can be repeated.")
+	rootCmd.Flags().StringArray("env-file", []string{}, "A dotenv file of environment variables to set in the container. Can be repeated.")
+	rootCmd.Flags().StringArray("env-s3-file", []string{}, "The ARN of an S3 hosted env file for ECS to load into the container. Can be repeated.")
+
 	// Add sub commands
 	rootCmd.AddCommand(InitCmd)
+	rootCmd.AddCommand(PsCmd)
+	rootCmd.AddCommand(StopCmd)
+	rootCmd.AddCommand(LogsCmd)
 }
 
 func initEnvVars() {
@@ -152,6 +311,7 @@ func initEnvVars() {
 	viper.BindEnv("cluster")
 	viper.BindEnv("task")
 	viper.BindEnv("cmd")
+	viper.BindEnv("shell-cmd", "ECSRUN_SHELL_CMD")
 	viper.BindEnv("subnet")
 	viper.BindEnv("security-group", "ECSRUN_SECURITY_GROUP")
 
@@ -166,26 +326,19 @@ func initVerbose() {
 	}
 }
 
-func initVersion() {
-	if viper.GetBool("version") {
-		fmt.Print(vInfo.String())
-		os.Exit(0)
-	}
-}
-
-func initAws() {
+func initAws() error {
 	profile := getProfile()
 	viper.Set("profile", profile)
 
 	// Create our AWS session object for AWS API Usage
 	sesh, err := initAwsSession(profile)
 	if err != nil {
-		log.Fatal("Unable to init AWS Session. Check your credentials and profile.", err)
+		return &CredentialError{Err: err}
 	}
 
 	region := viper.GetString("region")
 	if region == "" {
-		region = *sesh.Config.Region
+		region = aws.StringValue(sesh.Config.Region)
 	}
 
 	// Override our Session's region in case it was set.
@@ -193,6 +346,8 @@ func initAws() {
 
 	// Set our awsSession for later use.
 	viper.Set("session", sesh)
+
+	return nil
 }
 
 func getProfile() string {
@@ -218,20 +373,23 @@ func initAwsSession(profile string) (*session.Session, error) {
 			Credentials: credentials.NewSharedCredentials(credFile, profile),
 		})
 	} else {
-		sesh = session.Must(session.NewSessionWithOptions(session.Options{
+		sesh, err = session.NewSessionWithOptions(session.Options{
 			Profile:           profile,
 			SharedConfigState: session.SharedConfigEnable,
 			Config: aws.Config{
 				CredentialsChainVerboseErrors: aws.Bool(true),
-				Credentials: credentials.NewEnvCredentials(),
+				Credentials:                   credentials.NewEnvCredentials(),
 			},
-		}))
+		})
 	}
 
 	return sesh, err
 }
 
-func initConfigFile() error {
+// initConfigFile merges the --config entry of the config file into viper, if
+// there's a config file. The entry is required unless entryOptional is given,
+// in which case a missing entry is skipped the same as a missing file.
+func initConfigFile(entryOptional bool) error {
 	var filename string
 	var err error
 
@@ -239,50 +397,111 @@ func initConfigFile() error {
 
 	if cfgFile == "" {
 		filename, err = findConfigFile()
+
+		// Not having a config file is fine, everything can come from flags.
+		if err != nil {
+			log.Debug(err)
+			return nil
+		}
 	} else {
 		filename, err = findCustomConfigFile(cfgFile)
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+	}
+
+	log.Debug("Using config file: ", filename)
+
+	file, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return &ConfigError{Err: err}
 	}
 
+	config, err := parseConfigFile(filename, file)
 	if err != nil {
 		return err
 	}
 
-	log.Debug("Using config file: ", filename)
+	configEntry := viper.GetString("config")
+	if _, ok := config[configEntry]; !ok && entryOptional {
+		log.Debug("Config entry ", configEntry, " not found in ", filename, ", not using it.")
+		return nil
+	}
 
-	file, err := afero.ReadFile(fs, filename)
+	entry, err := getConfigEntry(config, filename, configEntry)
 	if err != nil {
 		return err
 	}
 
-	config := make(map[string]interface{})
-	if err := yaml.Unmarshal(file, &config); err != nil {
+	entryMap := entry.ToMap()
+	log.Debug("Config entry: ", configEntry, " result: ", entryMap)
+	if err = viper.MergeConfigMap(entryMap); err != nil {
 		return err
 	}
 
-	log.Debug("Full config file contents: ", config)
+	// Remember which entry we used so we can tag the task with it.
+	viper.Set("config-entry", configEntry)
 
-	// Ooof this is rough.
-	configEntry := viper.GetString("config")
-	rawEntry := config[configEntry].(map[interface{}]interface{})
-	entry := make(map[string]interface{})
-	for key, val := range rawEntry {
-		strKey := fmt.Sprintf("%v", key)
-		var resVal interface{}
-
-		if strKey == "cmd" {
-			resVal = val.([]interface{})
-		} else {
-			resVal = fmt.Sprintf("%v", val)
+	return nil
+}
+
+// initCommand works out the command override to run. In order of precedence
+// it comes from the args after --, --shell-cmd, or --cmd on the command line,
+// then ECSRUN_SHELL_CMD or ECSRUN_CMD, and then shell-cmd or cmd in the config
+// file. Only one of them can be given by each of those sources at a time.
+func initCommand(flags *pflag.FlagSet, args []string) error {
+	given := []string{}
+	if len(args) > 0 {
+		given = append(given, "--")
+	}
+	if flags.Changed("shell-cmd") {
+		given = append(given, "--shell-cmd")
+	}
+	if flags.Changed("cmd") {
+		given = append(given, "--cmd")
+	}
+
+	if len(given) > 1 {
+		return &ConfigError{Err: fmt.Errorf("only one of %s can be used to give the command", strings.Join(given, ", "))}
+	}
+
+	if len(args) > 0 {
+		viper.Set("cmd", args)
+		return nil
+	}
+
+	if flags.Changed("cmd") {
+		return nil
+	}
+
+	if !flags.Changed("shell-cmd") {
+		envCmd, envShellCmd := os.Getenv("ECSRUN_CMD") != "", os.Getenv("ECSRUN_SHELL_CMD") != ""
+		configCmd, configShellCmd := viper.InConfig("cmd"), viper.InConfig("shell-cmd")
+
+		switch {
+		case envCmd && envShellCmd:
+			return &ConfigError{Err: errors.New("only one of ECSRUN_CMD, ECSRUN_SHELL_CMD can be used to give the command")}
+		case envCmd:
+			// viper already gives ECSRUN_CMD precedence over the config file.
+			return nil
+		case !envShellCmd && configCmd && configShellCmd:
+			return &ConfigError{Err: fmt.Errorf("only one of cmd, shell-cmd can be used to give the command in config entry %s", viper.GetString("config-entry"))}
+		case !envShellCmd && configCmd:
+			return nil
 		}
+	}
 
-		entry[strKey] = resVal
+	shellCmd := viper.GetString("shell-cmd")
+	if shellCmd == "" {
+		return nil
 	}
 
-	log.Debug("Config entry: ", configEntry, " result: ", entry)
-	if err = viper.MergeConfigMap(entry); err != nil {
-		return err
+	words, err := splitShellWords(shellCmd)
+	if err != nil {
+		return &ConfigError{Err: fmt.Errorf("unable to parse shell-cmd: %s", err)}
 	}
 
+	viper.Set("cmd", words)
 	return nil
 }
 
@@ -297,7 +516,7 @@ func findCustomConfigFile(filename string) (string, error) {
 		return filename, nil
 	}
 
-	return "", errors.New("custom config file not found")
+	return "", fmt.Errorf("custom config file %s not found", filename)
 }
 
 func findConfigFile() (string, error) {
@@ -317,11 +536,10 @@ func findConfigFile() (string, error) {
 	return "", errors.New("config file not found")
 }
 
-// checkRequired maps over all the required flags and creates a nice err msg if
+// checkRequired maps over the given required flags and creates a nice err msg if
 // any are found. This is used instead of Cobra native required flags due to
 // the goofy configuration file setup.
-func checkRequired() error {
-	requiredFlags := []string{"cluster", "task", "cmd", "subnet", "security-group"}
+func checkRequired(requiredFlags ...string) error {
 	unsetFlags := []string{}
 	for _, flag := range requiredFlags {
 		if !viper.IsSet(flag) {
@@ -331,14 +549,13 @@ func checkRequired() error {
 
 	if len(unsetFlags) > 0 {
 		log.Debug("checkRequired - unsetFlags: ", unsetFlags)
-		red := color.New(color.FgRed)
 		redB := color.New(color.FgRed, color.Bold)
 
 		start := red.Sprintf("The following are required arguments: ")
 		reqArgs := redB.Sprintf("%s", strings.Join(unsetFlags, ", "))
 
-		errMsg := fmt.Sprintf("%s%s\n", start, reqArgs)
-		return errors.New(errMsg)
+		errMsg := fmt.Sprintf("%s%s", start, reqArgs)
+		return &ConfigError{Err: errors.New(errMsg)}
 	}
 
 	return nil
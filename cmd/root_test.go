@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"os"
-	"os/exec"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -19,14 +20,17 @@ import (
 
 var previousProfile string
 var runTaskCount int
+var restoreStsClient func()
 
 func setup() {
 	previousProfile = os.Getenv("AWS_PROFILE")
+	restoreStsClient = useStsAPIFake(&stsAPIFake{})
 
 	unsetRequired()
 }
 
 func teardown() {
+	restoreStsClient()
 	unsetRequired()
 	os.Setenv("AWS_PROFILE", previousProfile)
 	viper.Reset()
@@ -49,6 +53,7 @@ func unsetRequired() {
 	os.Unsetenv("AWS_ACCESS_KEY_ID")
 	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
 	os.Unsetenv("ECSRUN_CMD")
+	os.Unsetenv("ECSRUN_SHELL_CMD")
 	os.Unsetenv("ECSRUN_CLUSTER")
 	os.Unsetenv("ECSRUN_SECURITY_GROUP")
 	os.Unsetenv("ECSRUN_SUBNET")
@@ -73,6 +78,26 @@ func (c *ecsClientFake) RunTask(runTaskInput *ecs.RunTaskInput) (*ecs.RunTaskOut
 	return &ecs.RunTaskOutput{}, nil
 }
 
+func (c *ecsClientFake) ListTasks(desiredStatus string) ([]*string, error) {
+	return []*string{}, nil
+}
+
+func (c *ecsClientFake) DescribeTasks(taskArns []*string) ([]*ecs.Task, error) {
+	return []*ecs.Task{}, nil
+}
+
+func (c *ecsClientFake) DescribeTaskDefinition() (*ecs.TaskDefinition, error) {
+	return &ecs.TaskDefinition{}, nil
+}
+
+func (c *ecsClientFake) StopTask(taskArn, reason string) (*ecs.Task, error) {
+	return &ecs.Task{TaskArn: &taskArn}, nil
+}
+
+func (c *ecsClientFake) WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error) {
+	return []*ecs.Task{}, nil
+}
+
 func newEcsClientFake(c *RunConfig) ECSClient {
 	return &ecsClientFake{}
 }
@@ -100,49 +125,61 @@ func TestDryRun(t *testing.T) {
 	setup()
 
 	runTaskCount = 0
+	newEcsClient = newEcsClientFake
 
-	if os.Getenv("BE_CRASHER") == "1" {
-		setRequired()
-		viper.Set("dry-run", true)
-		Execute(newEcsClientFake, VersionInfo{})
-		return
-	}
-
-	c := exec.Command(os.Args[0], "-test.run=TestDryRun")
-	c.Env = append(os.Environ(), "BE_CRASHER=1")
-	err := c.Run()
+	setRequired()
+	viper.Set("dry-run", true)
+	err := rootCmd.RunE(rootCmd, []string{})
 
+	assert.Nil(err)
 	assert.Equal(0, runTaskCount)
-	if e, ok := err.(*exec.ExitError); ok && e.Success() {
-		teardown()
-		return
-	}
+
+	teardown()
 }
 
-// https://stackoverflow.com/a/33404435/1159410
 func TestCheckRequired(t *testing.T) {
 	assert := assert.New(t)
 	setup()
+
 	runTaskCount = 0
+	newEcsClient = newEcsClientFake
 
-	if os.Getenv("BE_CRASHER") == "1" {
-		setRequired()
-		os.Unsetenv("ECSRUN_CLUSTER")
+	setRequired()
+	os.Unsetenv("ECSRUN_CLUSTER")
+	err := rootCmd.RunE(rootCmd, []string{})
 
-		Execute(newEcsClientFake, VersionInfo{})
-		return
-	}
+	assert.Equal(0, runTaskCount)
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "cluster")
+	assert.Equal(ExitCodeConfig, ExitCode(err))
 
-	c := exec.Command(os.Args[0], "-test.run=TestCheckRequired")
-	c.Env = append(os.Environ(), "BE_CRASHER=1")
-	err := c.Run()
+	teardown()
+}
 
+func TestCheckRequiredNetwork(t *testing.T) {
+	assert := assert.New(t)
+	setup()
+
+	runTaskCount = 0
+	newEcsClient = newEcsClientFake
+
+	setRequired()
+	os.Unsetenv("ECSRUN_SUBNET")
+
+	// Fargate tasks use awsvpc so they need a subnet.
+	viper.Set("launch-type", "FARGATE")
+	err := rootCmd.RunE(rootCmd, []string{})
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "subnet")
+
+	// EC2 tasks using bridge networking don't.
+	viper.Set("launch-type", "EC2")
+	viper.Set("dry-run", true)
+	err = rootCmd.RunE(rootCmd, []string{})
+	assert.Nil(err)
 	assert.Equal(0, runTaskCount)
-	if e, ok := err.(*exec.ExitError); ok && !e.Success() {
-		teardown()
-		return
-	}
-	t.Fatalf("process ran with err %v, want exit status 1", err)
+
+	teardown()
 }
 
 func TestVersion(t *testing.T) {
@@ -172,14 +209,14 @@ func TestInitAws(t *testing.T) {
 
 	viper.Set("region", "random-region")
 
-	initAws()
+	assert.Nil(initAws())
 	sesh1 := viper.Get("session").(*session.Session)
 	assert.Equal(sesh1.Config.Region, aws.String("random-region"))
 
 	viper.Reset()
 	os.Setenv("AWS_REGION", "us-west-47")
 
-	initAws()
+	assert.Nil(initAws())
 	sesh2 := viper.Get("session").(*session.Session)
 	assert.Equal(sesh2.Config.Region, aws.String("us-west-47"))
 
@@ -194,12 +231,17 @@ func TestConfigFile(t *testing.T) {
 	viper.Set("config", "default")
 	viper.Set("config-file", "../example/configs/ecsrun.yaml")
 
-	err := initConfigFile()
+	err := initConfigFile(false)
 
 	assert.Nil(err)
 	assert.Equal("test-cluster", viper.Get("cluster"))
 	assert.Equal("test-task", viper.Get("task"))
-	assert.Equal("sg1", viper.Get("security-group"))
+	assert.Equal([]string{"sg1"}, viper.GetStringSlice("security-group"))
+
+	// A missing entry is only an error if it's required.
+	viper.Set("config", "nope")
+	assert.IsType(&ConfigError{}, initConfigFile(false))
+	assert.Nil(initConfigFile(true))
 
 	teardown()
 }
@@ -217,3 +259,97 @@ func TestGetProfile(t *testing.T) {
 
 	teardown()
 }
+
+func TestInitCommand(t *testing.T) {
+	assert := assert.New(t)
+	setup()
+
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.StringSlice("cmd", []string{}, "")
+		flags.String("shell-cmd", "", "")
+		return flags
+	}
+
+	// Args after -- are used as is.
+	flags := newFlags()
+	assert.Nil(initCommand(flags, []string{"python", "-c", "print(1,2)"}))
+	assert.Equal([]string{"python", "-c", "print(1,2)"}, viper.GetStringSlice("cmd"))
+
+	// shell-cmd is split using shell quoting rules.
+	viper.Reset()
+	viper.Set("shell-cmd", `bash -c "echo 'hello world'"`)
+	assert.Nil(initCommand(newFlags(), []string{}))
+	assert.Equal([]string{"bash", "-c", "echo 'hello world'"}, viper.GetStringSlice("cmd"))
+
+	// --cmd on the command line beats shell-cmd from the config file.
+	viper.Reset()
+	flags = newFlags()
+	flags.Set("cmd", "echo,hi")
+	viper.Set("cmd", []string{"echo", "hi"})
+	viper.Set("shell-cmd", "from config")
+	assert.Nil(initCommand(flags, []string{}))
+	assert.Equal([]string{"echo", "hi"}, viper.GetStringSlice("cmd"))
+
+	// Giving more than one on the command line is ambiguous.
+	err := initCommand(flags, []string{"echo"})
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "only one of --, --cmd")
+
+	viper.Reset()
+	viper.Set("shell-cmd", `echo "unterminated`)
+	err = initCommand(newFlags(), []string{})
+	assert.IsType(&ConfigError{}, err)
+
+	teardown()
+}
+
+func TestInitCommandSources(t *testing.T) {
+	assert := assert.New(t)
+	setup()
+	defer teardown()
+
+	// initCommand with the given env vars and config entry keys set.
+	run := func(env map[string]string, entry map[string]interface{}) error {
+		unsetRequired()
+		viper.Reset()
+		for key, value := range env {
+			os.Setenv(key, value)
+		}
+
+		initEnvVars()
+		assert.Nil(viper.MergeConfigMap(entry))
+		viper.Set("config-entry", "migrate")
+
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.StringSlice("cmd", []string{}, "")
+		flags.String("shell-cmd", "", "")
+
+		return initCommand(flags, []string{})
+	}
+
+	// shell-cmd in the config file is used when nothing else is given.
+	assert.Nil(run(nil, map[string]interface{}{"shell-cmd": "echo 'from config'"}))
+	assert.Equal([]string{"echo", "from config"}, viper.GetStringSlice("cmd"))
+
+	// The environment beats the config file, whichever of cmd and shell-cmd
+	// each gives.
+	assert.Nil(run(map[string]string{"ECSRUN_CMD": "from-env"}, map[string]interface{}{"shell-cmd": "echo 'from config'"}))
+	assert.Equal([]string{"from-env"}, viper.GetStringSlice("cmd"))
+
+	assert.Nil(run(map[string]string{"ECSRUN_SHELL_CMD": "echo 'from env'"}, map[string]interface{}{"cmd": []string{"echo", "from config"}}))
+	assert.Equal([]string{"echo", "from env"}, viper.GetStringSlice("cmd"))
+
+	// Giving both from the same source is ambiguous.
+	err := run(map[string]string{"ECSRUN_CMD": "echo", "ECSRUN_SHELL_CMD": "echo"}, nil)
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "only one of ECSRUN_CMD, ECSRUN_SHELL_CMD")
+
+	err = run(nil, map[string]interface{}{"cmd": []string{"echo"}, "shell-cmd": "echo"})
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), "only one of cmd, shell-cmd can be used to give the command in config entry migrate")
+
+	// Unless the environment overrides them both.
+	assert.Nil(run(map[string]string{"ECSRUN_CMD": "from-env"}, map[string]interface{}{"cmd": []string{"echo"}, "shell-cmd": "echo"}))
+	assert.Equal([]string{"from-env"}, viper.GetStringSlice("cmd"))
+}
@@ -1,28 +1,65 @@
 package cmd
 
 import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/spf13/viper"
 )
 
 // RunConfig is the main config object used to configure the RunTask.
 type RunConfig struct {
-	Command                []*string
-	Cluster                string
-	TaskDefinition         string
-	TaskDefinitionName     string
-	TaskDefinitionRevision string
-	ContainerName          string
-	LaunchType             string
-	Count                  int64
-
-	SubnetID           string
-	SecurityGroupID    string
+	Command                  []*string
+	Cluster                  string
+	TaskDefinition           string
+	TaskDefinitionName       string
+	TaskDefinitionRevision   string
+	ContainerName            string
+	LaunchType               string
+	CapacityProviderStrategy []CapacityProvider
+	SpotFallback             int
+	Count                    int64
+	Wait                     bool
+	OnInterrupt              string
+	Timeout                  time.Duration
+	Retries                  *RetryPolicy
+	Timeline                 string
+	Follow                   bool
+
+	CPU               int64
+	Memory            int64
+	ContainerCPU      int64
+	ContainerMemory   int64
+	MemoryReservation int64
+	EphemeralStorage  int64
+
+	Containers map[string]*ContainerConfig
+
+	TaskRoleArn      string
+	ExecutionRoleArn string
+
+	Environment      map[string]string
+	EnvironmentFiles []string
+
+	NetworkMode        string
+	SubnetIDs          []string
+	SecurityGroupIDs   []string
 	AssignPublicIPFlag bool
 	AssignPublicIP     string
 
+	RunInfo       RunInfo
+	NoTags        bool
+	Tags          map[string]string
+	PropagateTags string
+
 	Session *session.Session
+
+	// callerIdentity is looked up from STS when we first need it.
+	callerIdentity *sts.GetCallerIdentityOutput
 }
 
 // BuildRunConfig constructs the our primary RunConfig object using the given
@@ -37,21 +74,78 @@ func BuildRunConfig() *RunConfig {
 	assignPublicIP := getAssignPublicIP()
 	session := viper.Get("session").(*session.Session)
 
+	// A capacity provider strategy replaces the launch type.
+	launchType := viper.GetString("launch-type")
+	strategy := getCapacityProviderStrategy()
+	if len(strategy) > 0 {
+		launchType = ""
+	}
+
 	return &RunConfig{
-		Command:                cmd,
-		Cluster:                viper.GetString("cluster"),
-		TaskDefinition:         taskDef,
-		TaskDefinitionName:     viper.GetString("task"),
-		TaskDefinitionRevision: viper.GetString("revision"),
-		ContainerName:          name,
-		LaunchType:             viper.GetString("launch-type"),
-		Count:                  viper.GetInt64("count"),
-		SubnetID:               viper.GetString("subnet"),
-		SecurityGroupID:        viper.GetString("security-group"),
-		AssignPublicIPFlag:     viper.GetBool("public"),
-		AssignPublicIP:         assignPublicIP,
-		Session:                session,
+		Command:                  cmd,
+		Cluster:                  viper.GetString("cluster"),
+		TaskDefinition:           taskDef,
+		TaskDefinitionName:       viper.GetString("task"),
+		TaskDefinitionRevision:   viper.GetString("revision"),
+		ContainerName:            name,
+		LaunchType:               launchType,
+		CapacityProviderStrategy: strategy,
+		SpotFallback:             viper.GetInt("spot-fallback"),
+		Count:                    viper.GetInt64("count"),
+		Wait:                     viper.GetBool("wait"),
+		OnInterrupt:              viper.GetString("on-interrupt"),
+		Timeout:                  viper.GetDuration("timeout"),
+		Retries:                  getRetryPolicy(),
+		Timeline:                 viper.GetString("timeline"),
+		Follow:                   viper.GetBool("logs"),
+		CPU:                      viper.GetInt64("cpu"),
+		Memory:                   viper.GetInt64("memory"),
+		ContainerCPU:             viper.GetInt64("container-cpu"),
+		ContainerMemory:          viper.GetInt64("container-memory"),
+		MemoryReservation:        viper.GetInt64("memory-reservation"),
+		EphemeralStorage:         viper.GetInt64("ephemeral-storage"),
+		Containers:               getContainers(),
+		TaskRoleArn:              viper.GetString("task-role"),
+		ExecutionRoleArn:         viper.GetString("execution-role"),
+		Environment:              viper.GetStringMapString("environment"),
+		EnvironmentFiles:         viper.GetStringSlice("environment-files"),
+		NetworkMode:              viper.GetString("network-mode"),
+		SubnetIDs:                getStringList("subnet"),
+		SecurityGroupIDs:         getStringList("security-group"),
+		AssignPublicIPFlag:       viper.GetBool("public"),
+		AssignPublicIP:           assignPublicIP,
+		NoTags:                   viper.GetBool("no-tags"),
+		Tags:                     viper.GetStringMapString("tags"),
+		PropagateTags:            viper.GetString("propagate-tags"),
+		Session:                  session,
+	}
+}
+
+// initNetworkMode fills in the given config's NetworkMode if it wasn't given.
+// Fargate tasks always use awsvpc so we only need to look at the Task
+// Definition for other launch types. ECS defaults to bridge mode on EC2.
+func initNetworkMode(client ECSClient, config *RunConfig) error {
+	if config.NetworkMode != "" {
+		return nil
+	}
+
+	if config.LaunchType == ecs.LaunchTypeFargate || usesOnlyFargate(config.CapacityProviderStrategy) {
+		config.NetworkMode = ecs.NetworkModeAwsvpc
+		return nil
+	}
+
+	taskDef, err := client.DescribeTaskDefinition()
+	if err != nil {
+		return err
+	}
+
+	config.NetworkMode = aws.StringValue(taskDef.NetworkMode)
+	if config.NetworkMode == "" {
+		config.NetworkMode = ecs.NetworkModeBridge
 	}
+
+	log.Debug("Task definition network mode: ", config.NetworkMode)
+	return nil
 }
 
 func getNormalizedCmd() []*string {
@@ -64,6 +158,24 @@ func getNormalizedCmd() []*string {
 	return result
 }
 
+// getStringList reads a list from viper. Flags and the config file give us a
+// slice, but env vars come through as a single comma separated string.
+func getStringList(key string) []string {
+	value, ok := viper.Get(key).(string)
+	if !ok {
+		return viper.GetStringSlice(key)
+	}
+
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
 func getTaskDefinition() string {
 	if viper.GetString("revision") != "" {
 		return viper.GetString("task") + ":" + viper.GetString("revision")
@@ -3,6 +3,8 @@ package cmd
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -72,3 +74,43 @@ func TestGetTaskDefinition(t *testing.T) {
 	actual3 := getTaskDefinition()
 	assert.Equal(expected3, actual3)
 }
+
+func TestGetStringList(t *testing.T) {
+	assert := assert.New(t)
+
+	viper.Set("subnet", []string{"subnet-1", "subnet-2"})
+	assert.Equal([]string{"subnet-1", "subnet-2"}, getStringList("subnet"))
+
+	// Env vars come through as a comma separated string.
+	viper.Set("subnet", "subnet-1, subnet-2,")
+	assert.Equal([]string{"subnet-1", "subnet-2"}, getStringList("subnet"))
+
+	viper.Set("subnet", "subnet-1")
+	assert.Equal([]string{"subnet-1"}, getStringList("subnet"))
+
+	viper.Reset()
+}
+
+func TestInitNetworkMode(t *testing.T) {
+	assert := assert.New(t)
+
+	api := &ecsAPIFake{taskDefinition: &ecs.TaskDefinition{NetworkMode: aws.String("host")}}
+
+	fargate := &RunConfig{LaunchType: "FARGATE"}
+	assert.Nil(initNetworkMode(newClient(api, fargate), fargate))
+	assert.Equal("awsvpc", fargate.NetworkMode)
+
+	ec2 := &RunConfig{LaunchType: "EC2"}
+	assert.Nil(initNetworkMode(newClient(api, ec2), ec2))
+	assert.Equal("host", ec2.NetworkMode)
+
+	given := &RunConfig{LaunchType: "EC2", NetworkMode: "awsvpc"}
+	assert.Nil(initNetworkMode(newClient(api, given), given))
+	assert.Equal("awsvpc", given.NetworkMode)
+
+	// ECS defaults to bridge mode when the Task Definition doesn't say.
+	api.taskDefinition = &ecs.TaskDefinition{}
+	bridge := &RunConfig{LaunchType: "EC2"}
+	assert.Nil(initNetworkMode(newClient(api, bridge), bridge))
+	assert.Equal("bridge", bridge.NetworkMode)
+}
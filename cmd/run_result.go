@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// runResult is the outcome of a run in the json, yaml and table outputs. It's
+// kept stable so scripts can rely on the task ARNs, run ID and exit codes.
+type runResult struct {
+	RunID    string        `json:"runId"`
+	Cluster  string        `json:"cluster"`
+	TaskArns []string      `json:"taskArns"`
+	Tasks    []*taskResult `json:"tasks"`
+	Attempts []*attempt    `json:"attempts"`
+	ExitCode int           `json:"exitCode"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// taskResult is the last we saw of a single task we launched. The timeline and
+// diagnosis are only given for tasks we waited on.
+type taskResult struct {
+	TaskArn       string             `json:"taskArn"`
+	TaskID        string             `json:"taskId"`
+	LastStatus    string             `json:"lastStatus"`
+	StopCode      string             `json:"stopCode,omitempty"`
+	StoppedReason string             `json:"stoppedReason,omitempty"`
+	ExitCode      *int64             `json:"exitCode"`
+	Containers    []*containerResult `json:"containers"`
+	Timeline      *taskTimeline      `json:"timeline,omitempty"`
+	Diagnosis     *diagnosis         `json:"diagnosis,omitempty"`
+}
+
+type containerResult struct {
+	Name     string `json:"name"`
+	ExitCode *int64 `json:"exitCode"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// result builds the runResult of the tasks we launched. err is the error run
+// returned, if any.
+func (l *launcher) result(err error) *runResult {
+	result := &runResult{
+		RunID:    l.config.RunInfo.RunID,
+		Cluster:  l.config.Cluster,
+		TaskArns: []string{},
+		Tasks:    []*taskResult{},
+		Attempts: l.attempts,
+		ExitCode: ExitCode(err),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	for _, task := range l.tasks {
+		result.TaskArns = append(result.TaskArns, aws.StringValue(task.TaskArn))
+		result.Tasks = append(result.Tasks, l.taskResult(task))
+	}
+
+	return result
+}
+
+func (l *launcher) taskResult(task *ecs.Task) *taskResult {
+	result := &taskResult{
+		TaskArn:       aws.StringValue(task.TaskArn),
+		TaskID:        getTaskID(aws.StringValue(task.TaskArn)),
+		LastStatus:    aws.StringValue(task.LastStatus),
+		StopCode:      aws.StringValue(task.StopCode),
+		StoppedReason: aws.StringValue(task.StoppedReason),
+		Containers:    []*containerResult{},
+	}
+
+	for _, container := range task.Containers {
+		name := aws.StringValue(container.Name)
+		if name == l.config.ContainerName {
+			result.ExitCode = container.ExitCode
+		}
+
+		result.Containers = append(result.Containers, &containerResult{
+			Name:     name,
+			ExitCode: container.ExitCode,
+			Reason:   aws.StringValue(container.Reason),
+		})
+	}
+
+	if result.LastStatus == ecs.DesiredStatusStopped {
+		result.Timeline = buildTimeline(task, l.getLogConfig())
+		result.Diagnosis = diagnoseTask(task, l.checkTasks([]*ecs.Task{task}), l.config)
+	}
+
+	return result
+}
+
+// printTable prints a row for each task we launched.
+func (r *runResult) printTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tTASK ID\tSTATUS\tSTOP CODE\tEXIT CODE\tDURATION")
+
+	for _, task := range r.Tasks {
+		exitCode := "-"
+		if task.ExitCode != nil {
+			exitCode = fmt.Sprintf("%d", *task.ExitCode)
+		}
+
+		duration := ""
+		if task.Timeline != nil {
+			duration = task.Timeline.Duration
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			valueOrDash(r.RunID),
+			task.TaskID,
+			valueOrDash(task.LastStatus),
+			valueOrDash(task.StopCode),
+			exitCode,
+			valueOrDash(duration))
+	}
+
+	w.Flush()
+}
+
+// dryRunResult is what a run would launch in the json, yaml and table outputs.
+// The RunTaskInput is given the way the ECS API takes it.
+type dryRunResult struct {
+	RunID        string          `json:"runId"`
+	RunTaskInput json.RawMessage `json:"runTaskInput"`
+
+	input         *ecs.RunTaskInput
+	containerName string
+}
+
+func newDryRunResult(config *RunConfig, input *ecs.RunTaskInput) *dryRunResult {
+	return &dryRunResult{
+		RunID:         config.RunInfo.RunID,
+		RunTaskInput:  sdkJSON(input),
+		input:         input,
+		containerName: config.ContainerName,
+	}
+}
+
+// printTable prints a single row summarizing the RunTaskInput.
+func (r *dryRunResult) printTable(out io.Writer) {
+	command := []string{}
+	if r.input.Overrides != nil {
+		for _, override := range r.input.Overrides.ContainerOverrides {
+			if aws.StringValue(override.Name) == r.containerName {
+				command = aws.StringValueSlice(override.Command)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tTASK DEFINITION\tCAPACITY\tCOUNT\tCOMMAND")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+		aws.StringValue(r.input.Cluster),
+		aws.StringValue(r.input.TaskDefinition),
+		valueOrDash(describeCapacity(r.input)),
+		aws.Int64Value(r.input.Count),
+		valueOrDash(strings.Join(command, " ")))
+	w.Flush()
+}
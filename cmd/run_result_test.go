@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestLauncherResult(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{Cluster: "cluster", ContainerName: "app", RunInfo: RunInfo{RunID: "0123456789abcdef"}}
+	l := newLauncher(&ecsClientFake{}, config)
+
+	failed := finishedTask(testTaskArn, ecs.TaskStopCodeEssentialContainerExited, 127)
+	failed.Containers = append(failed.Containers, container("proxy", aws.Int64(0)))
+
+	running := launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/def456", "0123456789abcdef", "", "", testStartedAt)
+	running.LastStatus = aws.String("RUNNING")
+
+	l.track([]*ecs.Task{failed, running})
+	result := l.result(&TaskFailureError{TaskArn: aws.StringValue(failed.TaskArn), Code: 127, Reason: "exit code 127"})
+
+	assert.Equal("0123456789abcdef", result.RunID)
+	assert.Equal([]string{aws.StringValue(failed.TaskArn), aws.StringValue(running.TaskArn)}, result.TaskArns)
+	assert.Equal(127, result.ExitCode)
+	assert.NotEmpty(result.Error)
+
+	stopped := result.Tasks[0]
+	assert.Equal("abc123", stopped.TaskID)
+	assert.Equal(int64(127), *stopped.ExitCode)
+	assert.Len(stopped.Containers, 2)
+	assert.Equal("12m11s", stopped.Timeline.Duration)
+	assert.Equal("The container's command wasn't found (exit code 127).", stopped.Diagnosis.Summary)
+
+	// Tasks we didn't wait on don't have a timeline or diagnosis yet.
+	assert.Nil(result.Tasks[1].ExitCode)
+	assert.Nil(result.Tasks[1].Timeline)
+	assert.Nil(result.Tasks[1].Diagnosis)
+
+	out := &bytes.Buffer{}
+	result.printTable(out)
+	assert.Regexp(`0123456789abcdef\s+abc123\s+STOPPED\s+EssentialContainerExited\s+127\s+12m11s`, out.String())
+	assert.Regexp(`def456\s+RUNNING\s+-\s+-\s+-`, out.String())
+
+	// Without any tasks the task ARNs are still a list for scripts.
+	result = newLauncher(&ecsClientFake{}, config).result(errors.New("boom"))
+	assert.Equal([]string{}, result.TaskArns)
+	assert.Equal(ExitCodeError, result.ExitCode)
+}
+
+func TestDryRunResult(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &RunConfig{ContainerName: "app", RunInfo: RunInfo{RunID: "0123456789abcdef"}}
+	input := &ecs.RunTaskInput{
+		Cluster:        aws.String("cluster"),
+		TaskDefinition: aws.String("app:3"),
+		LaunchType:     aws.String("FARGATE"),
+		Count:          aws.Int64(1),
+		Overrides: &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{
+			Name:    aws.String("app"),
+			Command: aws.StringSlice([]string{"python", "manage.py", "migrate"}),
+		}}},
+	}
+
+	result := newDryRunResult(config, input)
+	assert.Contains(string(result.RunTaskInput), `"taskDefinition":"app:3"`)
+
+	out := &bytes.Buffer{}
+	result.printTable(out)
+	assert.Regexp(`cluster\s+app:3\s+FARGATE\s+1\s+python manage.py migrate`, out.String())
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+)
+
+// splitShellWords splits the given string into words using POSIX shell quoting
+// rules: words are separated by unquoted whitespace, single quotes preserve
+// everything literally, double quotes preserve everything except backslash
+// escapes of $ ` " \ and newline, and a backslash outside of quotes escapes
+// the next character. No expansion of variables, globs, etc. is done.
+func splitShellWords(input string) ([]string, error) {
+	words := []string{}
+
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, errors.New("unterminated backslash escape at end of command")
+			}
+
+			// A backslash-newline is a line continuation and is removed
+			// entirely, so it doesn't start a word on its own.
+			if runes[i] != '\n' {
+				inWord = true
+				word.WriteRune(runes[i])
+			}
+
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end == -1 {
+				return nil, errors.New("unterminated single quote in command")
+			}
+
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+
+				word.WriteRune(runes[i])
+			}
+
+			if i == len(runes) {
+				return nil, errors.New("unterminated double quote in command")
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// indexRune returns the index of the first instance of r in runes at or after
+// start, or -1 if it isn't present.
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
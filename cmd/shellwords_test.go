@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := map[string][]string{
+		"":                                  {},
+		"   ":                               {},
+		"python manage.py migrate":          {"python", "manage.py", "migrate"},
+		"  python   manage.py\tmigrate\n":   {"python", "manage.py", "migrate"},
+		`python -c 'print(1, 2)'`:           {"python", "-c", "print(1, 2)"},
+		`bash -c "echo \"Hello world\""`:    {"bash", "-c", `echo "Hello world"`},
+		`echo "$HOME \n"`:                   {"echo", `$HOME \n`},
+		`echo "\$HOME"`:                     {"echo", "$HOME"},
+		`echo 'it'\''s'`:                    {"echo", "it's"},
+		`echo hello\ world`:                 {"echo", "hello world"},
+		`echo ""`:                           {"echo", ""},
+		`echo a"b c"d`:                      {"echo", "ab cd"},
+		"echo one \\\ntwo":                  {"echo", "one", "two"},
+		"echo \\\n two":                     {"echo", "two"},
+		"echo one\\\ntwo":                   {"echo", "onetwo"},
+		`echo 'single "double" \backslash'`: {"echo", `single "double" \backslash`},
+	}
+
+	for input, expected := range cases {
+		actual, err := splitShellWords(input)
+		assert.Nil(err, input)
+		assert.Equal(expected, actual, input)
+	}
+}
+
+func TestSplitShellWordsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := splitShellWords(`echo 'unterminated`)
+	assert.EqualError(err, "unterminated single quote in command")
+
+	_, err = splitShellWords(`echo "unterminated`)
+	assert.EqualError(err, "unterminated double quote in command")
+
+	_, err = splitShellWords(`echo trailing\`)
+	assert.EqualError(err, "unterminated backslash escape at end of command")
+}
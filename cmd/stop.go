@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxStopReason is the longest stopped reason StopTask accepts.
+const maxStopReason = 255
+
+// stdin is swapped out in tests to answer the confirmation prompt.
+var stdin io.Reader = os.Stdin
+
+// StopCmd stops tasks launched by ecsrun.
+var StopCmd = &cobra.Command{
+	Use:   "stop [task-id|run-id...]",
+	Short: "Stops running tasks launched by ecsrun.",
+	Long: `Stops the running tasks launched by ecsrun that match the given task ARNs, task
+IDs, or run IDs. Without any, give --config to stop the tasks launched from that
+config entry and/or --mine to stop your own tasks.
+
+The tasks are listed and you're asked to confirm before they're stopped, unless
+--yes is given. Their stopped reason records who stopped them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := initOutput(outputText)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 && !viper.IsSet("config") && !viper.GetBool("mine") {
+			return &ConfigError{Err: errors.New("give the task IDs or run IDs to stop, or --config and/or --mine")}
+		}
+
+		config, err := initTaskCommand()
+		if err != nil {
+			return err
+		}
+
+		filter, err := getTaskFilter(config, !viper.GetBool("mine"))
+		if err != nil {
+			return err
+		}
+
+		client := newEcsClient(config)
+		tasks, err := findStoppableTasks(client, filter, args)
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) == 0 {
+			return &NotFoundError{Err: errors.New("no running tasks launched by ecsrun match")}
+		}
+
+		summaries := []*taskSummary{}
+		for _, task := range tasks {
+			summaries = append(summaries, summarizeTask(task))
+		}
+		printTaskSummariesTable(textOut, summaries)
+
+		results := []*stopResult{}
+		if !viper.GetBool("yes") && !confirm(fmt.Sprintf("Stop %d task(s)?", len(tasks))) {
+			fmt.Fprintln(textOut, "Not stopping any tasks.")
+		} else {
+			results, err = stopTasks(client, tasks, getStopReason(config))
+		}
+
+		// The text output is the progress stopTasks already printed.
+		if renderErr := render(format, results, func(out io.Writer) {
+			printStopResultsTable(out, results)
+		}, func(out io.Writer) {}); renderErr != nil {
+			log.Warn("Unable to print the stopped tasks. ", renderErr)
+		}
+
+		return err
+	},
+}
+
+func init() {
+	StopCmd.Flags().Bool("mine", false, "Only stop your own tasks. (default is false)")
+	StopCmd.Flags().BoolP("yes", "y", false, "Stop the tasks without asking for confirmation. (default is false)")
+
+	viper.BindPFlag("mine", StopCmd.Flags().Lookup("mine"))
+	viper.BindPFlag("yes", StopCmd.Flags().Lookup("yes"))
+}
+
+// findStoppableTasks finds the running tasks that match the given filter and,
+// if any are given, one of the given task ARNs, task IDs, or run IDs.
+func findStoppableTasks(client ECSClient, filter taskFilter, ids []string) ([]*ecs.Task, error) {
+	tasks, err := findTasks(client, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*ecs.Task{}
+	for _, task := range tasks {
+		if aws.StringValue(task.DesiredStatus) == ecs.DesiredStatusStopped {
+			continue
+		}
+
+		if len(ids) == 0 || matchesTaskID(task, ids) {
+			result = append(result, task)
+		}
+	}
+
+	return result, nil
+}
+
+// matchesTaskID reports whether the given task has one of the given task ARNs,
+// task IDs, or run IDs.
+func matchesTaskID(task *ecs.Task, ids []string) bool {
+	taskArn := aws.StringValue(task.TaskArn)
+	runID := getTaskTags(task)[tagRunID]
+
+	for _, id := range ids {
+		if id == taskArn || id == getTaskID(taskArn) || id == runID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// confirm asks the given yes / no question on stdin and defaults to no.
+func confirm(question string) bool {
+	fmt.Fprintf(textOut, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// getStopReason records who stopped the tasks, falling back to the hostname if
+// we can't look up the caller.
+func getStopReason(config *RunConfig) string {
+	who := "unknown"
+	if identity, err := getCallerIdentity(config); err == nil {
+		who = aws.StringValue(identity.Arn)
+	} else if hostname, err := os.Hostname(); err == nil {
+		who = hostname
+	}
+
+	reason := "Stopped with ecsrun by " + who
+	if len(reason) > maxStopReason {
+		reason = reason[:maxStopReason]
+	}
+
+	return reason
+}
+
+// stopResult is whether we were able to stop a single task.
+type stopResult struct {
+	TaskArn string `json:"taskArn"`
+	TaskID  string `json:"taskId"`
+	Stopped bool   `json:"stopped"`
+	Error   string `json:"error,omitempty"`
+}
+
+// stopTasks stops each of the given tasks, carrying on past any failures and
+// returning the first one along with the result for every task.
+func stopTasks(client ECSClient, tasks []*ecs.Task, reason string) ([]*stopResult, error) {
+	results := []*stopResult{}
+
+	var firstErr error
+	for _, task := range tasks {
+		taskArn := aws.StringValue(task.TaskArn)
+		result := &stopResult{TaskArn: taskArn, TaskID: getTaskID(taskArn)}
+		results = append(results, result)
+
+		if _, err := client.StopTask(taskArn, reason); err != nil {
+			log.Error("Unable to stop task ", taskArn, ". ", err)
+			result.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		result.Stopped = true
+		cyan.Fprintf(textOut, "Stopping task: %s\n", taskArn)
+	}
+
+	return results, firstErr
+}
+
+func printStopResultsTable(out io.Writer, results []*stopResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK ID\tRESULT")
+
+	for _, result := range results {
+		outcome := "stopping"
+		if !result.Stopped {
+			outcome = "failed: " + result.Error
+		}
+
+		fmt.Fprintf(w, "%s\t%s\n", result.TaskID, outcome)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks
+/////////
+
+// stopEcsClient records the tasks it's asked to stop and fails on the given one.
+type stopEcsClient struct {
+	tasksEcsClient
+	stopped []string
+	reasons []string
+	failArn string
+}
+
+func (c *stopEcsClient) StopTask(taskArn, reason string) (*ecs.Task, error) {
+	if taskArn == c.failArn {
+		return nil, &APIError{Op: "StopTask", Err: errors.New("AccessDenied")}
+	}
+
+	c.stopped = append(c.stopped, taskArn)
+	c.reasons = append(c.reasons, reason)
+	return &ecs.Task{TaskArn: &taskArn}, nil
+}
+
+// Tests
+/////////
+
+func TestFindStoppableTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	stopped := launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/ccc", "run-2", "migrate", "", testStartedAt)
+	stopped.DesiredStatus = aws.String(ecs.DesiredStatusStopped)
+	client := &tasksEcsClient{
+		running: []*ecs.Task{
+			launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/aaa", "run-1", "migrate", "", testStartedAt),
+			launchedTask("arn:aws:ecs:us-east-1:123456789012:task/cluster/bbb", "run-1", "migrate", "", testStartedAt),
+		},
+		stopped: []*ecs.Task{stopped},
+	}
+
+	tasks, err := findStoppableTasks(client, taskFilter{ConfigEntry: "migrate"}, []string{})
+	assert.Nil(err)
+	assert.Len(tasks, 2)
+
+	tasks, _ = findStoppableTasks(client, taskFilter{}, []string{"run-1"})
+	assert.Len(tasks, 2)
+
+	tasks, _ = findStoppableTasks(client, taskFilter{}, []string{"bbb"})
+	assert.Len(tasks, 1)
+	assert.Equal("arn:aws:ecs:us-east-1:123456789012:task/cluster/bbb", *tasks[0].TaskArn)
+
+	tasks, _ = findStoppableTasks(client, taskFilter{}, []string{"arn:aws:ecs:us-east-1:123456789012:task/cluster/aaa"})
+	assert.Len(tasks, 1)
+
+	// Tasks that are already stopping are skipped.
+	tasks, _ = findStoppableTasks(client, taskFilter{}, []string{"run-2"})
+	assert.Len(tasks, 0)
+}
+
+func TestConfirm(t *testing.T) {
+	assert := assert.New(t)
+
+	previousStdin := stdin
+	defer func() { stdin = previousStdin }()
+
+	for answer, expected := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		stdin = strings.NewReader(answer)
+		assert.Equal(expected, confirm("Stop?"), answer)
+	}
+}
+
+func TestStopTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &stopEcsClient{failArn: "arn:1"}
+	tasks := []*ecs.Task{{TaskArn: aws.String("arn:1")}, {TaskArn: aws.String("arn:2")}}
+
+	results, err := stopTasks(client, tasks, "Stopped with ecsrun by matt")
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+	assert.Equal([]string{"arn:2"}, client.stopped)
+	assert.Equal([]string{"Stopped with ecsrun by matt"}, client.reasons)
+
+	assert.Len(results, 2)
+	assert.False(results[0].Stopped)
+	assert.NotEmpty(results[0].Error)
+	assert.True(results[1].Stopped)
+
+	out := &bytes.Buffer{}
+	printStopResultsTable(out, results)
+	assert.Regexp(`arn:2\s+stopping`, out.String())
+	assert.Regexp(`arn:1\s+failed: `, out.String())
+}
+
+func TestGetStopReason(t *testing.T) {
+	assert := assert.New(t)
+	defer useStsAPIFake(&stsAPIFake{})()
+
+	assert.Equal("Stopped with ecsrun by arn:aws:iam::123456789012:user/matt", getStopReason(&RunConfig{}))
+}
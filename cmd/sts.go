@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// newStsClient is swapped out in tests to avoid calling STS.
+var newStsClient = NewStsClient
+
+// roleNameRegex matches an IAM role name with an optional path, e.g.
+// data-import or admin/data-import.
+var roleNameRegex = regexp.MustCompile(`^[\w+=,.@/-]+$`)
+
+// StsClient is the wrapper around the aws-sdk STS client.
+type StsClient interface {
+	GetCallerIdentity() (*sts.GetCallerIdentityOutput, error)
+}
+
+type stsClient struct {
+	client stsiface.STSAPI
+}
+
+// NewStsClient creates a new stsClient for the given RunConfig.
+func NewStsClient(config *RunConfig) StsClient {
+	return &stsClient{client: sts.New(config.Session)}
+}
+
+// GetCallerIdentity looks up the account and ARN of the credentials we're using.
+func (c *stsClient) GetCallerIdentity() (*sts.GetCallerIdentityOutput, error) {
+	output, err := c.client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, &APIError{Op: "GetCallerIdentity", Err: err}
+	}
+
+	return output, nil
+}
+
+// getCallerIdentity looks up the caller identity for the given config once and
+// reuses it after that.
+func getCallerIdentity(config *RunConfig) (*sts.GetCallerIdentityOutput, error) {
+	if config.callerIdentity != nil {
+		return config.callerIdentity, nil
+	}
+
+	identity, err := newStsClient(config).GetCallerIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	config.callerIdentity = identity
+	return identity, nil
+}
+
+// initRoles resolves the task and execution role overrides in the given config
+// to ARNs. Roles given by name are assumed to be in the caller's account, which
+// we look up with STS only if we need it.
+func initRoles(config *RunConfig) error {
+	for _, role := range []*string{&config.TaskRoleArn, &config.ExecutionRoleArn} {
+		if *role == "" || strings.HasPrefix(*role, "arn:") {
+			continue
+		}
+
+		identity, err := getCallerIdentity(config)
+		if err != nil {
+			return err
+		}
+
+		roleArn, err := getRoleArn(*role, identity)
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+
+		log.Debug("Resolved role ", *role, " to ", roleArn)
+		*role = roleArn
+	}
+
+	return nil
+}
+
+// getRoleArn builds the ARN of the given role name in the caller's account and
+// partition.
+func getRoleArn(name string, identity *sts.GetCallerIdentityOutput) (string, error) {
+	name = strings.Trim(name, "/")
+	if !roleNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid role name %q, expected a role name or ARN", name)
+	}
+
+	partition := "aws"
+	if callerArn, err := arn.Parse(aws.StringValue(identity.Arn)); err == nil {
+		partition = callerArn.Partition
+	}
+
+	return arn.ARN{
+		Partition: partition,
+		Service:   "iam",
+		AccountID: aws.StringValue(identity.Account),
+		Resource:  "role/" + name,
+	}.String(), nil
+}
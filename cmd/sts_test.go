@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks
+/////////
+
+type stsAPIFake struct {
+	stsiface.STSAPI
+
+	calls int
+	err   error
+}
+
+func (f *stsAPIFake) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	f.calls = f.calls + 1
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws:iam::123456789012:user/matt"),
+	}, nil
+}
+
+func useStsAPIFake(fake *stsAPIFake) func() {
+	previousNewStsClient := newStsClient
+	newStsClient = func(config *RunConfig) StsClient {
+		return &stsClient{client: fake}
+	}
+
+	return func() { newStsClient = previousNewStsClient }
+}
+
+// Tests
+/////////
+
+func TestInitRoles(t *testing.T) {
+	assert := assert.New(t)
+
+	fake := &stsAPIFake{}
+	defer useStsAPIFake(fake)()
+
+	config := &RunConfig{
+		TaskRoleArn:      "data-import",
+		ExecutionRoleArn: "admin/ecs-execution",
+	}
+	assert.Nil(initRoles(config))
+	assert.Equal("arn:aws:iam::123456789012:role/data-import", config.TaskRoleArn)
+	assert.Equal("arn:aws:iam::123456789012:role/admin/ecs-execution", config.ExecutionRoleArn)
+	assert.Equal(1, fake.calls)
+
+	// ARNs are used as is without calling STS.
+	config = &RunConfig{TaskRoleArn: "arn:aws:iam::999999999999:role/other"}
+	assert.Nil(initRoles(config))
+	assert.Equal("arn:aws:iam::999999999999:role/other", config.TaskRoleArn)
+	assert.Equal("", config.ExecutionRoleArn)
+	assert.Equal(1, fake.calls)
+}
+
+func TestInitRolesErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	fake := &stsAPIFake{err: errors.New("ExpiredToken")}
+	defer useStsAPIFake(fake)()
+
+	err := initRoles(&RunConfig{TaskRoleArn: "data-import"})
+	assert.Equal(ExitCodeAPI, ExitCode(err))
+	assert.Contains(err.Error(), "GetCallerIdentity")
+
+	fake.err = nil
+	err = initRoles(&RunConfig{TaskRoleArn: "data import"})
+	assert.Equal(ExitCodeConfig, ExitCode(err))
+}
+
+func TestGetRoleArn(t *testing.T) {
+	assert := assert.New(t)
+
+	identity := &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws-cn:sts::123456789012:assumed-role/admin/matt"),
+	}
+
+	roleArn, err := getRoleArn("data-import", identity)
+	assert.Nil(err)
+	assert.Equal("arn:aws-cn:iam::123456789012:role/data-import", roleArn)
+}
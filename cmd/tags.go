@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// The tags we add to every task we launch.
+const (
+	tagRunID       = "ecsrun:run-id"
+	tagCaller      = "ecsrun:caller"
+	tagHostname    = "ecsrun:hostname"
+	tagConfigEntry = "ecsrun:config"
+	tagGitCommit   = "ecsrun:git-commit"
+	tagMaxRuntime  = "ecsrun:max-runtime"
+)
+
+// propagateTagsNone is the default of not propagating any tags to the task.
+// RunTask only accepts TASK_DEFINITION so we leave it out instead of sending it.
+const propagateTagsNone = "NONE"
+
+// ECS allows 50 tags per task, keys of up to 128 characters and values of up
+// to 256. StartedBy can be up to 36 characters.
+const (
+	maxTags           = 50
+	runInfoTags       = 6
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+	maxStartedBy      = 36
+)
+
+var (
+	invalidTagRegex       = regexp.MustCompile(`[^\pL\pZ\pN_.:/=+\-@]`)
+	invalidStartedByRegex = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+)
+
+// getGitCommit is swapped out in tests so they don't depend on the repo they run in.
+var getGitCommit = func() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// RunInfo describes who launched a run and from where. It's added to every
+// task as tags, StartedBy and Group, and to the container as ECSRUN_* env vars.
+type RunInfo struct {
+	RunID       string
+	Caller      string
+	Hostname    string
+	ConfigEntry string
+	GitCommit   string
+	MaxRuntime  time.Duration
+}
+
+// initRunInfo gathers the RunInfo for the given config. None of it is required
+// to launch a task so anything we can't find out is left empty. The caller is
+// only looked up if lookupCaller is given, so dry runs don't call STS.
+func initRunInfo(config *RunConfig, configEntry string, lookupCaller bool) {
+	info := RunInfo{
+		RunID:       newRunID(),
+		ConfigEntry: configEntry,
+		GitCommit:   getGitCommit(),
+		MaxRuntime:  config.Timeout,
+	}
+
+	if lookupCaller {
+		if identity, err := getCallerIdentity(config); err != nil {
+			log.Warn("Unable to look up the caller identity to tag the task with. ", err)
+		} else {
+			info.Caller = aws.StringValue(identity.Arn)
+		}
+	}
+
+	if hostname, err := os.Hostname(); err != nil {
+		log.Warn("Unable to look up the hostname to tag the task with. ", err)
+	} else {
+		info.Hostname = hostname
+	}
+
+	log.Debug("Run info: ", info)
+	config.RunInfo = info
+}
+
+// newRunID generates a random ID to identify all of the tasks of a single run.
+func newRunID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// Tags returns the tags for the run, skipping anything we don't know. The max
+// runtime is given in seconds so that a cleanup job can stop the task even if
+// ecsrun isn't around to enforce the timeout itself.
+func (i RunInfo) Tags() map[string]string {
+	maxRuntime := ""
+	if i.MaxRuntime > 0 {
+		maxRuntime = strconv.FormatInt(int64(i.MaxRuntime/time.Second), 10)
+	}
+
+	return withoutEmpty(map[string]string{
+		tagRunID:       i.RunID,
+		tagCaller:      i.Caller,
+		tagHostname:    i.Hostname,
+		tagConfigEntry: i.ConfigEntry,
+		tagGitCommit:   i.GitCommit,
+		tagMaxRuntime:  maxRuntime,
+	})
+}
+
+// Environment returns the ECSRUN_* env vars for the run, skipping anything we
+// don't know.
+func (i RunInfo) Environment() map[string]string {
+	return withoutEmpty(map[string]string{
+		"ECSRUN_RUN_ID":       i.RunID,
+		"ECSRUN_CALLER":       i.Caller,
+		"ECSRUN_HOSTNAME":     i.Hostname,
+		"ECSRUN_CONFIG_ENTRY": i.ConfigEntry,
+		"ECSRUN_GIT_COMMIT":   i.GitCommit,
+	})
+}
+
+// StartedBy returns the StartedBy value for the run: the caller's name, e.g.
+// matt for arn:aws:iam::123456789012:user/matt, within the limits ECS allows.
+func (i RunInfo) StartedBy() string {
+	name := "ecsrun"
+	if i.Caller != "" {
+		name = callerName(i.Caller)
+	}
+
+	name = invalidStartedByRegex.ReplaceAllString(name, "_")
+	if len(name) > maxStartedBy {
+		name = name[:maxStartedBy]
+	}
+
+	return name
+}
+
+// Group returns the task group for the run, which is the config entry it came
+// from if any.
+func (i RunInfo) Group() string {
+	if i.ConfigEntry == "" {
+		return ""
+	}
+
+	return "ecsrun:" + i.ConfigEntry
+}
+
+func withoutEmpty(values map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range values {
+		if value != "" {
+			result[key] = value
+		}
+	}
+
+	return result
+}
+
+// validateTags checks the tags from the config file and the propagate-tags
+// setting against what ECS allows.
+func validateTags(tags map[string]string, propagateTags string) error {
+	if len(tags) > maxTags-runInfoTags {
+		return fmt.Errorf("too many tags, ECS allows %d including the %d ecsrun adds", maxTags, runInfoTags)
+	}
+
+	for key, value := range tags {
+		if key == "" || len(key) > maxTagKeyLength {
+			return fmt.Errorf("tag key %q must be between 1 and %d characters", key, maxTagKeyLength)
+		}
+
+		if strings.HasPrefix(strings.ToLower(key), "aws:") || strings.HasPrefix(key, "ecsrun:") {
+			return fmt.Errorf("tag key %q can't use the reserved aws: or ecsrun: prefixes", key)
+		}
+
+		if invalidTagRegex.MatchString(key) || invalidTagRegex.MatchString(value) {
+			return fmt.Errorf("tag %q can only contain letters, numbers, spaces and _ . : / = + - @", key)
+		}
+
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("tag %q value must be at most %d characters", key, maxTagValueLength)
+		}
+	}
+
+	switch propagateTags {
+	case "", ecs.PropagateTagsTaskDefinition, propagateTagsNone:
+		return nil
+	default:
+		return errors.New("propagate-tags must be TASK_DEFINITION or NONE")
+	}
+}
+
+// sanitizeTagValue cleans up the given value to fit the characters and length
+// ECS allows in a tag value.
+func sanitizeTagValue(value string) string {
+	value = invalidTagRegex.ReplaceAllString(value, "_")
+	if len(value) > maxTagValueLength {
+		value = value[:maxTagValueLength]
+	}
+
+	return value
+}
+
+// getTags converts the given tags, plus the ones for the run, to the sorted
+// list of tags that ECS expects. Values we gathered ourselves are cleaned up
+// to fit the characters and length ECS allows.
+func getTags(info RunInfo, tags map[string]string) []*ecs.Tag {
+	all := make(map[string]string)
+	for key, value := range info.Tags() {
+		all[key] = sanitizeTagValue(value)
+	}
+
+	for key, value := range tags {
+		all[key] = value
+	}
+
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := []*ecs.Tag{}
+	for _, key := range keys {
+		result = append(result, &ecs.Tag{Key: aws.String(key), Value: aws.String(all[key])})
+	}
+
+	return result
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+func testRunInfo() RunInfo {
+	return RunInfo{
+		RunID:       "0123456789abcdef",
+		Caller:      "arn:aws:sts::123456789012:assumed-role/Admin/matt@example.com",
+		Hostname:    "laptop.local",
+		ConfigEntry: "migrate",
+		GitCommit:   "4c1f1d8",
+	}
+}
+
+// Tests
+/////////
+
+func TestInitRunInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	previousGetGitCommit := getGitCommit
+	getGitCommit = func() string { return "4c1f1d8" }
+	defer func() { getGitCommit = previousGetGitCommit }()
+
+	defer useStsAPIFake(&stsAPIFake{})()
+	config := &RunConfig{}
+	initRunInfo(config, "migrate", true)
+
+	assert.Len(config.RunInfo.RunID, 16)
+	assert.Equal("arn:aws:iam::123456789012:user/matt", config.RunInfo.Caller)
+	assert.NotEmpty(config.RunInfo.Hostname)
+	assert.Equal("migrate", config.RunInfo.ConfigEntry)
+	assert.Equal("4c1f1d8", config.RunInfo.GitCommit)
+
+	// Not knowing the caller isn't fatal.
+	expired := &stsAPIFake{err: errors.New("ExpiredToken")}
+	defer useStsAPIFake(expired)()
+	config = &RunConfig{}
+	initRunInfo(config, "", true)
+	assert.Equal("", config.RunInfo.Caller)
+	assert.NotEmpty(config.RunInfo.RunID)
+
+	// Dry runs don't call STS at all.
+	calls := expired.calls
+	config = &RunConfig{}
+	initRunInfo(config, "", false)
+	assert.Equal("", config.RunInfo.Caller)
+	assert.Equal(calls, expired.calls)
+}
+
+func TestRunInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	info := testRunInfo()
+	assert.Equal("matt_example_com", info.StartedBy())
+	assert.Equal("ecsrun:migrate", info.Group())
+	assert.Equal("migrate", info.Tags()[tagConfigEntry])
+	assert.Equal("0123456789abcdef", info.Environment()["ECSRUN_RUN_ID"])
+	assert.Equal("4c1f1d8", info.Environment()["ECSRUN_GIT_COMMIT"])
+
+	info = RunInfo{RunID: "0123456789abcdef", Caller: "arn:aws:iam::123456789012:user/" + strings.Repeat("a", 40)}
+	assert.Len(info.StartedBy(), maxStartedBy)
+	assert.Equal("", info.Group())
+	assert.Len(info.Tags(), 2)
+	assert.Len(info.Environment(), 2)
+
+	assert.Equal("ecsrun", RunInfo{}.StartedBy())
+
+	// The max runtime is tagged in seconds when there's a timeout.
+	info.MaxRuntime = 30 * time.Minute
+	assert.Equal("1800", info.Tags()[tagMaxRuntime])
+}
+
+func TestValidateTags(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateTags(map[string]string{"team": "data", "cost-center": "1234"}, ""))
+	assert.Nil(validateTags(nil, "TASK_DEFINITION"))
+	assert.Nil(validateTags(nil, "NONE"))
+
+	assert.EqualError(validateTags(nil, "SERVICE"), "propagate-tags must be TASK_DEFINITION or NONE")
+	assert.EqualError(validateTags(map[string]string{"aws:owner": "me"}, ""), `tag key "aws:owner" can't use the reserved aws: or ecsrun: prefixes`)
+	assert.EqualError(validateTags(map[string]string{"ecsrun:run-id": "me"}, ""), `tag key "ecsrun:run-id" can't use the reserved aws: or ecsrun: prefixes`)
+	assert.EqualError(validateTags(map[string]string{"owner": "me!"}, ""), `tag "owner" can only contain letters, numbers, spaces and _ . : / = + - @`)
+	assert.EqualError(validateTags(map[string]string{"owner": strings.Repeat("a", 257)}, ""), `tag "owner" value must be at most 256 characters`)
+
+	tooMany := map[string]string{}
+	for i := 0; i < 45; i++ {
+		tooMany[strings.Repeat("a", i+1)] = "x"
+	}
+	assert.EqualError(validateTags(tooMany, ""), "too many tags, ECS allows 50 including the 6 ecsrun adds")
+}
+
+func TestGetTags(t *testing.T) {
+	assert := assert.New(t)
+
+	info := testRunInfo()
+	info.Hostname = "matt's laptop"
+
+	tags := getTags(info, map[string]string{"team": "data"})
+	assert.Len(tags, 6)
+	assert.Equal(&ecs.Tag{Key: aws.String(tagCaller), Value: aws.String(info.Caller)}, tags[0])
+	assert.Equal(&ecs.Tag{Key: aws.String(tagHostname), Value: aws.String("matt_s laptop")}, tags[3])
+	assert.Equal(&ecs.Tag{Key: aws.String("team"), Value: aws.String("data")}, tags[5])
+
+	assert.Empty(getTags(RunInfo{}, nil))
+}
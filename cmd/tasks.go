@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+)
+
+// now is swapped out in tests so durations are predictable.
+var now = time.Now
+
+// taskFilter picks out the ecsrun launched tasks that the ps, stop and logs
+// commands work with. Empty fields match every task.
+type taskFilter struct {
+	ConfigEntry string
+	Caller      string
+	RunID       string
+}
+
+// initTaskCommand sets up the AWS session and config file for the commands that
+// work with tasks that were already launched and returns their RunConfig.
+func initTaskCommand() (*RunConfig, error) {
+	initEnvVars()
+	if err := initAws(); err != nil {
+		return nil, err
+	}
+
+	// These commands only need the cluster, so a config file without the
+	// default entry is fine unless --config was given.
+	if err := initConfigFile(!viper.IsSet("config")); err != nil {
+		return nil, err
+	}
+
+	if err := checkRequired("cluster"); err != nil {
+		return nil, err
+	}
+
+	return BuildRunConfig(), nil
+}
+
+// findTasks fetches the running and recently stopped tasks in the cluster that
+// were launched by ecsrun and match the given filter, newest first.
+func findTasks(client ECSClient, filter taskFilter) ([]*ecs.Task, error) {
+	taskArns := []*string{}
+	for _, status := range []string{ecs.DesiredStatusRunning, ecs.DesiredStatusStopped} {
+		arns, err := client.ListTasks(status)
+		if err != nil {
+			return nil, err
+		}
+
+		taskArns = append(taskArns, arns...)
+	}
+
+	// A stopped task can age out between listing and describing it, in which
+	// case it's just not there anymore.
+	tasks, err := client.DescribeTasks(taskArns)
+	if err != nil && !isMissingTasks(err) {
+		return nil, err
+	}
+
+	result := []*ecs.Task{}
+	for _, task := range tasks {
+		if filter.matches(task) {
+			result = append(result, task)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return aws.TimeValue(result[i].CreatedAt).After(aws.TimeValue(result[j].CreatedAt))
+	})
+
+	return result, nil
+}
+
+func (f taskFilter) matches(task *ecs.Task) bool {
+	tags := getTaskTags(task)
+
+	if _, ok := tags[tagRunID]; !ok {
+		return false
+	}
+
+	for key, value := range map[string]string{
+		tagConfigEntry: f.ConfigEntry,
+		tagCaller:      sanitizeTagValue(f.Caller),
+		tagRunID:       f.RunID,
+	} {
+		if value != "" && tags[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getTaskTags converts the tags of the given task to a map.
+func getTaskTags(task *ecs.Task) map[string]string {
+	result := make(map[string]string)
+	for _, tag := range task.Tags {
+		result[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return result
+}
+
+// getMainContainerOverride returns the override for the container ecsrun ran
+// the command in, which is always the first one.
+func getMainContainerOverride(task *ecs.Task) *ecs.ContainerOverride {
+	if task.Overrides == nil || len(task.Overrides.ContainerOverrides) == 0 {
+		return nil
+	}
+
+	return task.Overrides.ContainerOverrides[0]
+}
+
+// getMainContainer returns the container ecsrun ran the command in.
+func getMainContainer(task *ecs.Task) *ecs.Container {
+	override := getMainContainerOverride(task)
+	if override == nil {
+		return nil
+	}
+
+	for _, container := range task.Containers {
+		if aws.StringValue(container.Name) == aws.StringValue(override.Name) {
+			return container
+		}
+	}
+
+	return nil
+}
+
+// getTaskDuration returns how long the given task has been, or was, running.
+func getTaskDuration(task *ecs.Task) time.Duration {
+	if task.StartedAt == nil {
+		return 0
+	}
+
+	end := now()
+	if task.StoppedAt != nil {
+		end = *task.StoppedAt
+	}
+
+	return end.Sub(*task.StartedAt).Round(time.Second)
+}
+
+// callerName returns the name of the IAM user or role session in the given
+// caller ARN, e.g. matt for arn:aws:iam::123456789012:user/matt.
+func callerName(callerArn string) string {
+	parsed, err := arn.Parse(callerArn)
+	if err != nil {
+		return callerArn
+	}
+
+	parts := strings.Split(parsed.Resource, "/")
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+func useNow(t time.Time) func() {
+	previousNow := now
+	now = func() time.Time { return t }
+
+	return func() { now = previousNow }
+}
+
+// Mocks
+/////////
+
+// tasksEcsClient lists and describes a fixed set of tasks.
+type tasksEcsClient struct {
+	ecsClientFake
+	running []*ecs.Task
+	stopped []*ecs.Task
+
+	// forgotten tasks are listed but have aged out by the time they're described.
+	forgotten []string
+
+	describeCalls []int
+}
+
+func (c *tasksEcsClient) ListTasks(desiredStatus string) ([]*string, error) {
+	tasks := c.running
+	if desiredStatus == ecs.DesiredStatusStopped {
+		tasks = c.stopped
+	}
+
+	result := []*string{}
+	for _, task := range tasks {
+		result = append(result, task.TaskArn)
+	}
+
+	if desiredStatus == ecs.DesiredStatusStopped {
+		result = append(result, aws.StringSlice(c.forgotten)...)
+	}
+
+	return result, nil
+}
+
+func (c *tasksEcsClient) DescribeTasks(taskArns []*string) ([]*ecs.Task, error) {
+	all := map[string]*ecs.Task{}
+	for _, task := range append(append([]*ecs.Task{}, c.running...), c.stopped...) {
+		all[aws.StringValue(task.TaskArn)] = task
+		all[getTaskID(aws.StringValue(task.TaskArn))] = task
+	}
+
+	result := []*ecs.Task{}
+	missing := []string{}
+	for _, arn := range taskArns {
+		task, ok := all[aws.StringValue(arn)]
+		if !ok {
+			missing = append(missing, aws.StringValue(arn))
+			continue
+		}
+
+		result = append(result, task)
+	}
+
+	c.describeCalls = append(c.describeCalls, len(taskArns))
+	if len(missing) > 0 {
+		return result, &APIError{Op: "DescribeTasks", Err: &missingTasksError{TaskArns: missing}}
+	}
+
+	return result, nil
+}
+
+// Tests
+/////////
+
+func TestFindTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	matt := "arn:aws:iam::123456789012:user/matt"
+	client := &tasksEcsClient{
+		running: []*ecs.Task{
+			launchedTask("arn:1", "run-1", "migrate", matt, testStartedAt),
+			launchedTask("arn:2", "run-2", "backfill", "arn:aws:iam::123456789012:user/gowi", testStartedAt.Add(time.Minute)),
+			{TaskArn: aws.String("arn:service"), CreatedAt: aws.Time(testStartedAt)},
+		},
+		stopped: []*ecs.Task{
+			launchedTask("arn:3", "run-3", "migrate", matt, testStartedAt.Add(time.Hour)),
+		},
+	}
+
+	// Tasks not launched by ecsrun are skipped and the rest are newest first.
+	tasks, err := findTasks(client, taskFilter{})
+	assert.Nil(err)
+	assert.Len(tasks, 3)
+	assert.Equal("arn:3", *tasks[0].TaskArn)
+	assert.Equal("arn:2", *tasks[1].TaskArn)
+
+	tasks, _ = findTasks(client, taskFilter{Caller: matt})
+	assert.Len(tasks, 2)
+
+	tasks, _ = findTasks(client, taskFilter{ConfigEntry: "backfill"})
+	assert.Len(tasks, 1)
+	assert.Equal("arn:2", *tasks[0].TaskArn)
+
+	tasks, _ = findTasks(client, taskFilter{RunID: "run-1", Caller: matt})
+	assert.Len(tasks, 1)
+	assert.Equal("arn:1", *tasks[0].TaskArn)
+
+	// A stopped task that ages out before it's described is left out.
+	client.forgotten = []string{"arn:4"}
+	tasks, err = findTasks(client, taskFilter{})
+	assert.Nil(err)
+	assert.Len(tasks, 3)
+}
+
+func TestGetMainContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	task := &ecs.Task{
+		Overrides: &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{Name: aws.String("app")}}},
+		Containers: []*ecs.Container{
+			container("proxy", aws.Int64(0)),
+			container("app", aws.Int64(3)),
+		},
+	}
+
+	assert.Equal("app", *getMainContainerOverride(task).Name)
+	assert.Equal(int64(3), *getMainContainer(task).ExitCode)
+
+	assert.Nil(getMainContainerOverride(&ecs.Task{}))
+	assert.Nil(getMainContainer(&ecs.Task{}))
+}
+
+func TestGetTaskDuration(t *testing.T) {
+	assert := assert.New(t)
+	defer useNow(testStartedAt.Add(90 * time.Second))()
+
+	assert.Equal(time.Duration(0), getTaskDuration(&ecs.Task{}))
+	assert.Equal(90*time.Second, getTaskDuration(&ecs.Task{StartedAt: aws.Time(testStartedAt)}))
+	assert.Equal(30*time.Second, getTaskDuration(&ecs.Task{
+		StartedAt: aws.Time(testStartedAt),
+		StoppedAt: aws.Time(testStartedAt.Add(30*time.Second + 200*time.Millisecond)),
+	}))
+}
+
+func TestCallerName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("matt", callerName("arn:aws:iam::123456789012:user/matt"))
+	assert.Equal("matt@example.com", callerName("arn:aws:sts::123456789012:assumed-role/Admin/matt@example.com"))
+	assert.Equal("not-an-arn", callerName("not-an-arn"))
+}
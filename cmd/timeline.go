@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// Whether the timeline of a waited on task is printed. It's only ever printed
+// as a table since --output json already includes it in the run's result.
+const (
+	timelineTable = "table"
+	timelineNone  = "none"
+)
+
+// validateTimeline checks the timeline setting is one we know.
+func validateTimeline(format string) error {
+	switch format {
+	case "", timelineTable, timelineNone:
+		return nil
+	default:
+		return &ConfigError{Err: fmt.Errorf("invalid timeline %q, expected table or none", format)}
+	}
+}
+
+// timelinePhase is the time a task spent between two of its lifecycle events.
+type timelinePhase struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+}
+
+// taskTimeline is how long each phase of a stopped task's lifecycle took, e.g.
+// pulling its image versus actually running, along with links to the task and
+// its logs in the AWS console.
+type taskTimeline struct {
+	TaskArn    string           `json:"taskArn"`
+	TaskID     string           `json:"taskId"`
+	Phases     []*timelinePhase `json:"phases"`
+	Duration   string           `json:"duration"`
+	ConsoleURL string           `json:"consoleUrl"`
+	LogsURL    string           `json:"logsUrl,omitempty"`
+}
+
+// lifecycleEvent is a timestamp from DescribeTasks and the phase it starts.
+type lifecycleEvent struct {
+	phase string
+	at    *time.Time
+}
+
+// buildTimeline builds the timeline of the given stopped task. The phases
+// come from whichever of its timestamps ECS reported, in the order they
+// happened. logConfig is used to link to the task's logs if it's given.
+func buildTimeline(task *ecs.Task, logConfig *awsLogConfig) *taskTimeline {
+	taskArn := aws.StringValue(task.TaskArn)
+	timeline := &taskTimeline{
+		TaskArn:    taskArn,
+		TaskID:     getTaskID(taskArn),
+		Phases:     []*timelinePhase{},
+		ConsoleURL: getTaskConsoleURL(task),
+	}
+
+	if logConfig != nil {
+		timeline.LogsURL = getLogStreamConsoleURL(taskArn, logConfig)
+	}
+
+	events := []lifecycleEvent{}
+	for _, event := range []lifecycleEvent{
+		{"Provisioning", task.CreatedAt},
+		{"Pulling image", task.PullStartedAt},
+		{"Starting", task.PullStoppedAt},
+		{"Running", task.StartedAt},
+		{"Stopping", task.StoppingAt},
+		{"Deprovisioning", task.ExecutionStoppedAt},
+		{"", task.StoppedAt},
+	} {
+		if event.at != nil {
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(*events[j].at) })
+
+	for idx := 0; idx+1 < len(events); idx++ {
+		if events[idx].phase == "" {
+			continue
+		}
+
+		start, end := *events[idx].at, *events[idx+1].at
+		timeline.Phases = append(timeline.Phases, &timelinePhase{
+			Name:     events[idx].phase,
+			Start:    start,
+			End:      end,
+			Duration: formatPhaseDuration(end.Sub(start)),
+		})
+	}
+
+	if len(events) > 1 {
+		timeline.Duration = formatPhaseDuration(events[len(events)-1].at.Sub(*events[0].at))
+	}
+
+	return timeline
+}
+
+// formatPhaseDuration rounds the given duration so it's readable, keeping the
+// milliseconds of the phases that take less than a second.
+func formatPhaseDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return duration.Round(time.Millisecond).String()
+	}
+
+	return duration.Round(time.Second).String()
+}
+
+// getTaskConsoleURL links to the given task's page in the ECS console.
+func getTaskConsoleURL(task *ecs.Task) string {
+	region := getArnRegion(aws.StringValue(task.TaskArn))
+	cluster := getTaskID(aws.StringValue(task.ClusterArn))
+
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/ecs/v2/clusters/%s/tasks/%s/configuration?region=%s",
+		region, url.PathEscape(cluster), getTaskID(aws.StringValue(task.TaskArn)), region)
+}
+
+// getLogStreamConsoleURL links to the given task's log stream in the
+// CloudWatch console, which expects the group and stream to be escaped with $
+// in place of %.
+func getLogStreamConsoleURL(taskArn string, logConfig *awsLogConfig) string {
+	region := logConfig.Region
+	if region == "" {
+		region = getArnRegion(taskArn)
+	}
+
+	escape := func(value string) string {
+		return strings.ReplaceAll(url.QueryEscape(value), "%", "$25")
+	}
+
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#logsV2:log-groups/log-group/%s/log-events/%s",
+		region, region, escape(logConfig.Group), escape(logConfig.StreamName(taskArn)))
+}
+
+func getArnRegion(value string) string {
+	parsed, err := arn.Parse(value)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Region
+}
+
+func printTimelinesTable(out io.Writer, timelines []*taskTimeline) {
+	for _, timeline := range timelines {
+		cyan.Fprintf(out, "Timeline for task %s:\n", timeline.TaskID)
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PHASE\tSTART\tDURATION")
+		for _, phase := range timeline.Phases {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", phase.Name, phase.Start.Local().Format("2006-01-02 15:04:05"), phase.Duration)
+		}
+		fmt.Fprintf(w, "Total\t\t%s\n", valueOrDash(timeline.Duration))
+		w.Flush()
+
+		fmt.Fprintf(out, "Console: %s\n", timeline.ConsoleURL)
+		if timeline.LogsURL != "" {
+			fmt.Fprintf(out, "Logs: %s\n", timeline.LogsURL)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests
+/////////
+
+func TestBuildTimeline(t *testing.T) {
+	assert := assert.New(t)
+
+	timeline := buildTimeline(stoppedTask(), &awsLogConfig{Group: "/ecs/app", StreamPrefix: "ecs", ContainerName: "app"})
+
+	names := []string{}
+	durations := []string{}
+	for _, phase := range timeline.Phases {
+		names = append(names, phase.Name)
+		durations = append(durations, phase.Duration)
+	}
+
+	assert.Equal([]string{"Provisioning", "Pulling image", "Starting", "Running", "Deprovisioning", "Stopping"}, names)
+	assert.Equal([]string{"30s", "1m5s", "5s", "10m0s", "1s", "30s"}, durations)
+	assert.Equal("12m11s", timeline.Duration)
+	assert.Equal("abc123", timeline.TaskID)
+	assert.Equal("https://us-east-1.console.aws.amazon.com/ecs/v2/clusters/cluster/tasks/abc123/configuration?region=us-east-1", timeline.ConsoleURL)
+	assert.Equal("https://us-east-1.console.aws.amazon.com/cloudwatch/home?region=us-east-1#logsV2:log-groups/log-group/$252Fecs$252Fapp/log-events/ecs$252Fapp$252Fabc123", timeline.LogsURL)
+
+	// A task that never pulled or started only has the phases ECS reported.
+	task := &ecs.Task{TaskArn: stoppedTask().TaskArn, CreatedAt: stoppedTask().CreatedAt, StoppedAt: aws.Time(testStartedAt.Add(250 * time.Millisecond))}
+	timeline = buildTimeline(task, nil)
+
+	assert.Len(timeline.Phases, 1)
+	assert.Equal("250ms", timeline.Phases[0].Duration)
+	assert.Equal("", timeline.LogsURL)
+}
+
+func TestPrintTimelines(t *testing.T) {
+	assert := assert.New(t)
+
+	timelines := []*taskTimeline{buildTimeline(stoppedTask(), nil)}
+
+	var out bytes.Buffer
+	printTimelinesTable(&out, timelines)
+	assert.Contains(out.String(), "Timeline for task abc123:")
+	assert.Regexp(`Pulling image\s+\S+ \S+\s+1m5s`, out.String())
+	assert.Regexp(`Total\s+12m11s`, out.String())
+	assert.Contains(out.String(), "Console: https://us-east-1.console.aws.amazon.com/ecs/v2/clusters/cluster/tasks/abc123")
+	assert.NotContains(out.String(), "Logs:")
+
+}
+
+func TestValidateTimeline(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, valid := range []string{"", timelineTable, timelineNone} {
+		assert.Nil(validateTimeline(valid), valid)
+	}
+
+	assert.IsType(&ConfigError{}, validateTimeline("yaml"))
+	// JSON timelines are part of the result printed by --output json instead.
+	assert.IsType(&ConfigError{}, validateTimeline("json"))
+}
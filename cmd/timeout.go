@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/viper"
+)
+
+// timeoutStopReason is the stopped reason of tasks we stop for running past
+// their timeout.
+const timeoutStopReason = "ecsrun timeout"
+
+// initTimeout parses the timeout from the flag or config file, e.g. 30m or
+// 2h, so a typo is reported instead of silently disabling the timeout.
+func initTimeout() error {
+	value := viper.GetString("timeout")
+	if value == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return &ConfigError{Err: fmt.Errorf("invalid timeout %q, expected a duration like 30m or 2h", value)}
+	}
+
+	if timeout < 0 {
+		return &ConfigError{Err: errors.New("timeout must not be negative")}
+	}
+
+	viper.Set("timeout", timeout)
+	return nil
+}
+
+// withDeadline returns a copy of the given context that's done once the run's
+// timeout is up, if it has one.
+func (l *launcher) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, l.deadline)
+}
+
+// stopTimedOut stops the given tasks once they've run past the timeout and
+// returns a TimeoutError for them.
+func (l *launcher) stopTimedOut(taskArns []*string) error {
+	log.Error("The task(s) didn't finish within the ", l.config.Timeout, " timeout. Stopping them.")
+
+	for _, taskArn := range taskArns {
+		if _, err := l.client.StopTask(aws.StringValue(taskArn), timeoutStopReason); err != nil {
+			log.Error("Unable to stop task ", aws.StringValue(taskArn), ". ", err)
+		}
+	}
+
+	arns := strings.Join(aws.StringValueSlice(taskArns), ", ")
+	return &TimeoutError{Err: fmt.Errorf("task(s) didn't finish within the %s timeout and were stopped: %s", l.config.Timeout, arns)}
+}
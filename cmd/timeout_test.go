@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks
+/////////
+
+// hungEcsClient launches tasks that never stop on their own and records the
+// tasks it's asked to stop.
+type hungEcsClient struct {
+	scriptedEcsClient
+	stopped []string
+	reasons []string
+}
+
+func (c *hungEcsClient) WaitForTasks(ctx context.Context, taskArns []*string) ([]*ecs.Task, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func (c *hungEcsClient) StopTask(taskArn, reason string) (*ecs.Task, error) {
+	c.stopped = append(c.stopped, taskArn)
+	c.reasons = append(c.reasons, reason)
+
+	return &ecs.Task{TaskArn: aws.String(taskArn)}, nil
+}
+
+// failingEcsClient launches tasks that stop right away with the scripted
+// results and records the tasks it's asked to stop.
+type failingEcsClient struct {
+	scriptedEcsClient
+	stopped []string
+}
+
+func (c *failingEcsClient) StopTask(taskArn, reason string) (*ecs.Task, error) {
+	c.stopped = append(c.stopped, taskArn)
+
+	return &ecs.Task{TaskArn: aws.String(taskArn)}, nil
+}
+
+// Tests
+/////////
+
+func TestInitTimeout(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	assert.Nil(initTimeout())
+	assert.Equal(time.Duration(0), viper.GetDuration("timeout"))
+
+	viper.Set("timeout", "30m")
+	assert.Nil(initTimeout())
+	assert.Equal(30*time.Minute, viper.GetDuration("timeout"))
+
+	viper.Set("timeout", "30")
+	err := initTimeout()
+	assert.IsType(&ConfigError{}, err)
+	assert.Contains(err.Error(), `invalid timeout "30"`)
+
+	viper.Set("timeout", "-5m")
+	assert.IsType(&ConfigError{}, initTimeout())
+}
+
+func TestLauncherTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &hungEcsClient{
+		scriptedEcsClient: scriptedEcsClient{runs: []scriptedRun{{output: startedTasks("arn:1", "arn:2")}}},
+	}
+
+	// A timeout implies waiting on the tasks.
+	l := newLauncher(client, &RunConfig{Timeout: 10 * time.Millisecond})
+	err := l.run(&ecs.RunTaskInput{Count: aws.Int64(2)})
+
+	assert.IsType(&TimeoutError{}, err)
+	assert.Equal(ExitCodeTimeout, ExitCode(err))
+	assert.Contains(err.Error(), "didn't finish within the 10ms timeout")
+	assert.Equal([]string{"arn:1", "arn:2"}, client.stopped)
+	assert.Equal([]string{timeoutStopReason, timeoutStopReason}, client.reasons)
+	assert.Equal("timed out", l.attempts[0].Outcome)
+}
+
+func TestLauncherTimeoutDuringRetryBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := &RetryPolicy{MaxAttempts: 3}
+	assert.Nil(initRetryPolicy(policy))
+	policy.backoff = time.Hour
+
+	client := &failingEcsClient{
+		scriptedEcsClient: scriptedEcsClient{runs: []scriptedRun{
+			{output: startedTasks("arn:1"), tasks: []*ecs.Task{finishedTask("arn:1", "EssentialContainerExited", 1)}},
+		}},
+	}
+
+	// The task fails right away but the backoff runs past the timeout, so it's
+	// never relaunched and nothing is left for the timeout to stop.
+	l := newLauncher(client, &RunConfig{ContainerName: "app", Timeout: 10 * time.Millisecond, Retries: policy})
+	err := l.run(&ecs.RunTaskInput{Count: aws.Int64(1)})
+
+	assert.IsType(&TimeoutError{}, err)
+	assert.Contains(err.Error(), "the 10ms timeout passed before the failed task(s) could be retried")
+	assert.Len(client.inputs, 1)
+	assert.Empty(client.stopped)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// getEssentialContainers returns the names of the containers in the given Task
+// Definition that are marked essential. ECS treats a container as essential
+// unless it explicitly says otherwise.
+func getEssentialContainers(taskDef *ecs.TaskDefinition) map[string]bool {
+	result := make(map[string]bool)
+	if taskDef == nil {
+		return result
+	}
+
+	for _, def := range taskDef.ContainerDefinitions {
+		if def.Essential == nil || *def.Essential {
+			result[aws.StringValue(def.Name)] = true
+		}
+	}
+
+	return result
+}
+
+// getTaskFailure determines whether the given stopped task failed and, if so,
+// which exit status ecsrun should use for it. The first non-zero exit code of
+// an essential container wins. If we don't know which containers are essential
+// we fall back to the container we overrode. Returns nil if the task succeeded.
+func getTaskFailure(task *ecs.Task, essential map[string]bool, containerName string) error {
+	failure := &TaskFailureError{TaskArn: aws.StringValue(task.TaskArn)}
+	stoppedReason := aws.StringValue(task.StoppedReason)
+
+	if task.StartedAt == nil {
+		failure.Code = ExitCodeNotStarted
+		failure.Reason = "stopped before its containers started: " + stoppedReason
+		return failure
+	}
+
+	if len(essential) == 0 {
+		essential = map[string]bool{containerName: true}
+	}
+
+	missingExitCode := ""
+	for _, container := range task.Containers {
+		name := aws.StringValue(container.Name)
+		if !essential[name] {
+			continue
+		}
+
+		if container.ExitCode == nil {
+			missingExitCode = name
+			continue
+		}
+
+		if *container.ExitCode != 0 {
+			failure.Code = int(*container.ExitCode)
+			failure.Reason = fmt.Sprintf("container %s exited with code %d", name, *container.ExitCode)
+			return failure
+		}
+	}
+
+	if missingExitCode != "" {
+		failure.Code = ExitCodeNoExitCode
+		failure.Reason = fmt.Sprintf("container %s stopped without an exit code: %s", missingExitCode, stoppedReason)
+		return failure
+	}
+
+	return nil
+}
+
+// getTasksFailure returns the first failure of any of the given stopped tasks.
+func getTasksFailure(tasks []*ecs.Task, essential map[string]bool, containerName string) error {
+	for _, task := range tasks {
+		if err := getTaskFailure(task, essential, containerName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allTasksStopped checks that we've got the expected number of tasks and that
+// every one of them has reached the STOPPED status.
+func allTasksStopped(tasks []*ecs.Task, expected int) bool {
+	if len(tasks) != expected {
+		return false
+	}
+
+	for _, task := range tasks {
+		if aws.StringValue(task.LastStatus) != ecs.DesiredStatusStopped {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getTaskArns pulls the ARNs of the tasks we launched out of the given RunTaskOutput.
+func getTaskArns(output *ecs.RunTaskOutput) []*string {
+	result := []*string{}
+	for _, task := range output.Tasks {
+		result = append(result, task.TaskArn)
+	}
+
+	return result
+}
+
+// waitForTasks blocks until the tasks in the given RunTaskOutput have stopped
+// and reports on how each of them finished.
+func waitForTasks(ctx context.Context, client ECSClient, output *ecs.RunTaskOutput) ([]*ecs.Task, error) {
+	taskArns := getTaskArns(output)
+	if len(taskArns) == 0 {
+		return nil, &TaskFailureError{Code: ExitCodeNotStarted, Reason: "no tasks were started so there is nothing to wait on"}
+	}
+
+	log.Info("Waiting for ", len(taskArns), " task(s) to stop.")
+	tasks, err := client.WaitForTasks(ctx, taskArns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		cyan.Fprintf(textOut, "Task stopped: %s\n", aws.StringValue(task.TaskArn))
+		fmt.Fprintf(textOut, "Stopped reason: %s\n", aws.StringValue(task.StoppedReason))
+		for _, container := range task.Containers {
+			exitCode := "none"
+			if container.ExitCode != nil {
+				exitCode = fmt.Sprintf("%d", *container.ExitCode)
+			}
+
+			fmt.Fprintf(textOut, "Container %s exit code: %s\n", aws.StringValue(container.Name), exitCode)
+		}
+	}
+
+	return tasks, nil
+}
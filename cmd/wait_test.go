@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helpers
+///////////
+
+// testTaskArn is the task the helpers below build unless they're given one.
+const testTaskArn = "arn:aws:ecs:us-east-1:123456789012:task/cluster/abc123"
+
+var testStartedAt = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func describedTask(arn, status string) *ecs.Task {
+	return &ecs.Task{TaskArn: aws.String(arn), LastStatus: aws.String(status)}
+}
+
+// stoppedTask builds a task that stopped with the given containers. Its
+// lifecycle starts at testStartedAt: 30s provisioning, 1m5s pulling, 5s
+// starting, 10m running, then 31s stopping and deprovisioning.
+func stoppedTask(containers ...*ecs.Container) *ecs.Task {
+	at := func(seconds int) *time.Time { return aws.Time(testStartedAt.Add(time.Duration(seconds) * time.Second)) }
+
+	task := describedTask(testTaskArn, ecs.DesiredStatusStopped)
+	task.ClusterArn = aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/cluster")
+	task.CreatedAt = at(0)
+	task.PullStartedAt = at(30)
+	task.PullStoppedAt = at(95)
+	task.StartedAt = at(100)
+	task.ExecutionStoppedAt = at(700)
+	task.StoppingAt = at(701)
+	task.StoppedAt = at(731)
+	task.Containers = containers
+
+	return task
+}
+
+// finishedTask builds a stopped task whose app container exited with the
+// given code.
+func finishedTask(arn string, stopCode string, exitCode int64) *ecs.Task {
+	task := stoppedTask(container("app", aws.Int64(exitCode)))
+	task.TaskArn = aws.String(arn)
+	task.StopCode = aws.String(stopCode)
+
+	return task
+}
+
+// launchedTask builds a running task tagged the way ecsrun tags its tasks.
+func launchedTask(arn, runID, config, caller string, created time.Time) *ecs.Task {
+	task := tagged(describedTask(arn, "RUNNING"), runID, config, caller)
+	task.CreatedAt = aws.Time(created)
+
+	return task
+}
+
+// tagged adds the given run info tags to the task.
+func tagged(task *ecs.Task, runID, config, caller string) *ecs.Task {
+	task.Tags = []*ecs.Tag{
+		{Key: aws.String(tagRunID), Value: aws.String(runID)},
+		{Key: aws.String(tagConfigEntry), Value: aws.String(config)},
+		{Key: aws.String(tagCaller), Value: aws.String(caller)},
+	}
+
+	return task
+}
+
+// ranCommand records that the task's first container was run with the given
+// command override.
+func ranCommand(task *ecs.Task, command ...string) *ecs.Task {
+	task.Overrides = &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{
+		Name:    task.Containers[0].Name,
+		Command: aws.StringSlice(command),
+	}}}
+
+	return task
+}
+
+// stoppedWith records why ECS stopped the task and its first container.
+func stoppedWith(task *ecs.Task, stoppedReason, containerReason string) *ecs.Task {
+	task.StoppedReason = aws.String(stoppedReason)
+	task.Containers[0].Reason = aws.String(containerReason)
+
+	return task
+}
+
+func container(name string, exitCode *int64) *ecs.Container {
+	return &ecs.Container{
+		Name:     aws.String(name),
+		ExitCode: exitCode,
+	}
+}
+
+// Tests
+/////////
+
+func TestGetEssentialContainers(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(getEssentialContainers(nil))
+
+	taskDef := &ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("app")},
+			{Name: aws.String("worker"), Essential: aws.Bool(true)},
+			{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+		},
+	}
+
+	actual := getEssentialContainers(taskDef)
+	assert.Equal(map[string]bool{"app": true, "worker": true}, actual)
+}
+
+func TestGetTaskFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	essential := map[string]bool{"app": true}
+
+	success := stoppedTask(container("app", aws.Int64(0)), container("sidecar", aws.Int64(137)))
+	assert.Nil(getTaskFailure(success, essential, "app"))
+
+	failed := stoppedTask(container("app", aws.Int64(3)))
+	err := getTaskFailure(failed, essential, "app")
+	assert.Equal(3, ExitCode(err))
+	assert.Contains(err.Error(), "container app exited with code 3")
+
+	missing := stoppedTask(container("app", nil))
+	missing.StoppedReason = aws.String("Task stopped by user")
+	err = getTaskFailure(missing, essential, "app")
+	assert.Equal(ExitCodeNoExitCode, ExitCode(err))
+	assert.Contains(err.Error(), "Task stopped by user")
+
+	notStarted := stoppedTask(container("app", nil))
+	notStarted.StartedAt = nil
+	assert.Equal(ExitCodeNotStarted, ExitCode(getTaskFailure(notStarted, essential, "app")))
+
+	// Falls back to the named container when we don't know what is essential.
+	fallback := stoppedTask(container("app", aws.Int64(0)), container("other", aws.Int64(1)))
+	assert.Nil(getTaskFailure(fallback, map[string]bool{}, "app"))
+	assert.Equal(1, ExitCode(getTaskFailure(fallback, map[string]bool{}, "other")))
+}
+
+func TestGetTasksFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	essential := map[string]bool{"app": true}
+	tasks := []*ecs.Task{
+		stoppedTask(container("app", aws.Int64(0))),
+		stoppedTask(container("app", aws.Int64(2))),
+	}
+
+	assert.Equal(2, ExitCode(getTasksFailure(tasks, essential, "app")))
+	assert.Nil(getTasksFailure(tasks[:1], essential, "app"))
+}
+
+func TestGetTaskArns(t *testing.T) {
+	assert := assert.New(t)
+
+	output := &ecs.RunTaskOutput{
+		Tasks: []*ecs.Task{
+			{TaskArn: aws.String("arn-1")},
+			{TaskArn: aws.String("arn-2")},
+		},
+	}
+
+	assert.Equal([]*string{aws.String("arn-1"), aws.String("arn-2")}, getTaskArns(output))
+	assert.Empty(getTaskArns(&ecs.RunTaskOutput{}))
+}